@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+)
+
+// projectAccessHints are fragments of the RetCode messages UCloud returns for a project that was
+// deleted or that the credential may no longer use; like quota refusals they have no dedicated
+// documented RetCode, so the message is matched
+var projectAccessHints = []string{
+	"verifyac",
+	"permission",
+	"access denied",
+	"no auth",
+	"project not exist",
+	"project does not exist",
+	"projectid not available",
+	"项目不存在",
+	"无权限",
+	"没有权限",
+}
+
+// isProjectAccessError recognizes an API refusal caused by the project itself (gone, or access
+// revoked) rather than by a failing call
+func isProjectAccessError(err error) bool {
+	var ue uerr.Error
+	if !errors.As(err, &ue) || ue.Name() != uerr.ErrRetCode {
+		return false
+	}
+	msg := strings.ToLower(ue.Message())
+	for _, h := range projectAccessHints {
+		if strings.Contains(msg, h) {
+			return true
+		}
+	}
+	return false
+}
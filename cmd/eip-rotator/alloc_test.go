@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/eip-rotator/eiprotator"
+)
+
+func TestAllocateEmptySetFindsEIPOnLaterPage(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	// more EIPs than one DescribeEIP page, so both discovery and the lookup have to page
+	for i := 0; i < eiprotator.PageSize+20; i++ {
+		f.add("cn-bj2", "org-test", unboundEIP(fmt.Sprintf("eip-spare%03d", i), fmt.Sprintf("117.51.0.%d", i), "spare"))
+	}
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	// just as recent and for the same host, but another task's
+	other := unboundEIP("eip-other", "117.50.9.9", "eip-rotator:other:uhost-web01")
+	other.CreateTime = int(time.Now().Unix())
+	f.add("cn-bj2", "org-test", other)
+	f.allocEmpty, f.listDelay = 1, 1
+
+	report, err := rotateOnce(context.Background(), task)
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Discovered != 1 || report.Rotated != 1 {
+		t.Fatalf("report = %+v, want 1 discovered, 1 rotated", report)
+	}
+	if got := f.callsOf("AllocateEIP"); len(got) != 1 {
+		t.Errorf("AllocateEIP called %d times, want once", len(got))
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new1"}) {
+		t.Errorf("web-01 bound to %v, want [eip-new1]", got)
+	}
+}
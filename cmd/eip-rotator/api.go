@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiRecentRotations is how many rotation records the control API keeps in memory
+const apiRecentRotations = 500
+
+// apiTask is a scheduled task as the control API reports it
+type apiTask struct {
+	Name        string    `json:"name"`
+	Key         string    `json:"key"`
+	Region      string    `json:"region,omitempty"`
+	Regions     []string  `json:"regions,omitempty"`
+	Projects    []string  `json:"project_ids"`
+	IntervalSec int       `json:"interval_sec"`
+	Started     time.Time `json:"started"`
+	Runs        int       `json:"runs"`
+	// the fields below describe the last finished run and are empty until the first one
+	LastRun         *time.Time `json:"last_run,omitempty"`
+	LastRunDuration string     `json:"last_run_duration,omitempty"`
+	LastRotated     int        `json:"last_rotated"`
+	LastFailed      int        `json:"last_failed"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// controlState is the scheduler state served by --api-addr: the active tasks and the most recent
+// rotation records (the same records --audit-log writes, with the public key redacted)
+type controlState struct {
+	mu        sync.Mutex
+	tasks     map[string]*apiTask // task key -> task
+	rotations []auditRecord       // oldest first, at most apiRecentRotations
+}
+
+var control = &controlState{tasks: map[string]*apiTask{}}
+
+func (c *controlState) taskStarted(key string, t taskConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tasks[key] = &apiTask{Name: taskID(t), Key: key, Region: t.Region, Regions: t.Regions, Projects: t.Projects, IntervalSec: t.Interval, Started: time.Now().UTC()}
+}
+
+// taskUpdated refreshes a task whose config changed in place, keeping its run history
+func (c *controlState) taskUpdated(key string, t taskConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a, ok := c.tasks[key]; ok {
+		a.Region, a.Regions, a.Projects = t.Region, t.Regions, t.Projects
+	}
+}
+
+func (c *controlState) taskStopped(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tasks, key)
+}
+
+// runFinished is fed from the scheduler's onResult
+func (c *controlState) runFinished(key string, r runResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tasks[key]
+	if !ok {
+		return
+	}
+	started := r.Started.UTC()
+	t.Runs++
+	t.LastRun = &started
+	t.LastRunDuration = r.Duration.Round(time.Millisecond).String()
+	t.LastRotated, t.LastFailed, t.LastError = r.Report.Rotated, r.Report.Failed, ""
+	if r.Err != nil && !errors.Is(r.Err, errNoBindings) {
+		t.LastError = r.Err.Error()
+	}
+}
+
+func (c *controlState) rotated(rec auditRecord) {
+	rec.PublicKey = redactKey(rec.PublicKey, 4)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rotations = append(c.rotations, rec)
+	if n := len(c.rotations) - apiRecentRotations; n > 0 {
+		c.rotations = append(c.rotations[:0], c.rotations[n:]...)
+	}
+}
+
+// startAPIServer serves the control API on addr in the background
+func startAPIServer(addr, token string) {
+	go func() {
+		if err := http.ListenAndServe(addr, apiHandler(token)); err != nil {
+			stdLogger().Errorf("api server on %s stopped: %v", addr, err)
+		}
+	}()
+	stdLogger().Infof("control api listening on %s/api/v1/", addr)
+}
+
+// apiHandler is the control API. Every request needs "Authorization: Bearer <token>".
+//
+//	GET /api/v1/tasks                     active tasks with the outcome of their last run
+//	GET /api/v1/rotations?limit=N&task=X  recent rotations, newest first (default limit 100)
+func apiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/tasks", func(w http.ResponseWriter, r *http.Request) {
+		control.mu.Lock()
+		tasks := make([]apiTask, 0, len(control.tasks))
+		for _, t := range control.tasks {
+			tasks = append(tasks, *t)
+		}
+		control.mu.Unlock()
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+		writeAPIJSON(w, tasks)
+	})
+	mux.HandleFunc("/api/v1/rotations", func(w http.ResponseWriter, r *http.Request) {
+		limit := 100
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		task := r.URL.Query().Get("task")
+		control.mu.Lock()
+		out := []auditRecord{}
+		for i := len(control.rotations) - 1; i >= 0 && len(out) < limit; i-- {
+			if task == "" || control.rotations[i].Task == task {
+				out = append(out, control.rotations[i])
+			}
+		}
+		control.mu.Unlock()
+		writeAPIJSON(w, out)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeAPIJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		stdLogger().Warnf("api: write response: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestControlAPI(t *testing.T) {
+	prev := control
+	control = &controlState{tasks: map[string]*apiTask{}}
+	defer func() { control = prev }()
+
+	web := taskConfig{Name: "web", PublicKey: "pubkey-web", Projects: []string{"org-a"}, Region: "cn-bj2", Interval: 600}
+	control.taskStarted("key-web", web)
+	control.taskStarted("key-db", taskConfig{Name: "db", Projects: []string{"org-b"}, Region: "hk", Interval: 300})
+	control.runFinished("key-web", runResult{Task: web, Started: time.Now(), Duration: time.Second, Report: rotateReport{Rotated: 2, Failed: 1}, Err: errors.New("unbind refused")})
+	for _, host := range []string{"uhost-1", "uhost-2", "uhost-3"} {
+		control.rotated(auditRecord{Task: "web", HostID: host, PublicKey: "pubkey-web"})
+	}
+	control.rotated(auditRecord{Task: "db", HostID: "uhost-db", PublicKey: "pubkey-db"})
+
+	srv := httptest.NewServer(apiHandler("secret"))
+	defer srv.Close()
+	get := func(method, path, token string, v any) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	for _, tt := range []struct {
+		method, path, token string
+		status              int
+	}{
+		{"GET", "/api/v1/tasks", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/tasks", "wrong", http.StatusUnauthorized},
+		{"POST", "/api/v1/tasks", "secret", http.StatusMethodNotAllowed},
+		{"GET", "/api/v1/rotations?limit=0", "secret", http.StatusBadRequest},
+		{"GET", "/api/v1/rotations?limit=x", "secret", http.StatusBadRequest},
+		{"GET", "/api/v1/unknown", "secret", http.StatusNotFound},
+	} {
+		if got := get(tt.method, tt.path, tt.token, nil); got != tt.status {
+			t.Errorf("%s %s with token %q = %d, want %d", tt.method, tt.path, tt.token, got, tt.status)
+		}
+	}
+
+	var tasks []apiTask
+	if code := get("GET", "/api/v1/tasks", "secret", &tasks); code != http.StatusOK {
+		t.Fatalf("GET /api/v1/tasks = %d", code)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "db" || tasks[1].Name != "web" {
+		t.Fatalf("tasks = %+v, want db and web sorted by name", tasks)
+	}
+	if w := tasks[1]; w.Runs != 1 || w.LastRotated != 2 || w.LastFailed != 1 || w.LastError != "unbind refused" || w.LastRun == nil {
+		t.Errorf("web = %+v, want its last run", w)
+	}
+	if d := tasks[0]; d.Runs != 0 || d.LastRun != nil {
+		t.Errorf("db = %+v, want no run yet", d)
+	}
+
+	tests := []struct {
+		query string
+		hosts []string
+	}{
+		{"", []string{"uhost-db", "uhost-3", "uhost-2", "uhost-1"}},
+		{"?limit=2", []string{"uhost-db", "uhost-3"}},
+		{"?task=web&limit=2", []string{"uhost-3", "uhost-2"}},
+		{"?task=cache", nil},
+	}
+	for _, tt := range tests {
+		var recs []auditRecord
+		if code := get("GET", "/api/v1/rotations"+tt.query, "secret", &recs); code != http.StatusOK {
+			t.Fatalf("GET /api/v1/rotations%s = %d", tt.query, code)
+		}
+		var hosts []string
+		for _, r := range recs {
+			hosts = append(hosts, r.HostID)
+			if r.PublicKey != "pubk****" {
+				t.Errorf("rotation public key = %q, want it redacted", r.PublicKey)
+			}
+		}
+		if !reflect.DeepEqual(hosts, tt.hosts) {
+			t.Errorf("rotations%s = %v, want %v", tt.query, hosts, tt.hosts)
+		}
+	}
+
+	// only the most recent rotations are kept
+	for i := 0; i < apiRecentRotations; i++ {
+		control.rotated(auditRecord{Task: "web", HostID: "uhost-x"})
+	}
+	var recs []auditRecord
+	get("GET", "/api/v1/rotations?task=db", "secret", &recs)
+	if len(recs) != 0 || len(control.rotations) != apiRecentRotations {
+		t.Errorf("kept %d rotations with db's still among them (%d), want the last %d", len(control.rotations), len(recs), apiRecentRotations)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+// armMarkerPrefix starts the token ArmGracePeriodSec appends to an old EIP's Remark the first time
+// it would be rotated; the token carries the unix time it was armed. Removing it from the Remark
+// before the grace period elapses cancels the rotation (the next run arms the EIP again).
+const armMarkerPrefix = "eip-rotator-armed@"
+
+var armMarkerPattern = regexp.MustCompile(regexp.QuoteMeta(armMarkerPrefix) + `(\d+)`)
+
+// armedAt returns when the EIP with this Remark was armed
+func armedAt(remark string) (time.Time, bool) {
+	m := armMarkerPattern.FindStringSubmatch(remark)
+	if m == nil {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// armRemark appends the arm token to remark, keeping whatever the remark said before
+func armRemark(remark string, now time.Time) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s%d", remark, armMarkerPrefix, now.Unix()))
+}
+
+// checkArmed returns a non-empty reason when b must not be rotated yet: an unarmed EIP is armed
+// now, and an armed one waits until it has carried the token for ArmGracePeriodSec
+func checkArmed(client *unet.UNetClient, task taskConfig, b hostBinding) (string, error) {
+	grace := time.Duration(task.ArmGracePeriodSec) * time.Second
+	if at, ok := armedAt(b.EIPRemark); ok {
+		if since := time.Since(at); since < grace {
+			return fmt.Sprintf("armed %s ago, rotates after arm_grace_period_sec=%d", since.Round(time.Second), task.ArmGracePeriodSec), nil
+		}
+		return "", nil
+	}
+	req := client.NewUpdateEIPAttributeRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.EIPId = ucloud.String(b.EIPID)
+	req.Remark = ucloud.String(armRemark(b.EIPRemark, time.Now()))
+	if _, err := client.UpdateEIPAttribute(req); err != nil {
+		return "", fmt.Errorf("arm UpdateEIPAttribute: region=%s host=%s(%s) eip=%s: %w", b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, err)
+	}
+	return fmt.Sprintf("armed as rotation candidate, rotates after arm_grace_period_sec=%d unless %s is removed from its remark", task.ArmGracePeriodSec, armMarkerPrefix), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArmGracePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		remark    string
+		rotated   bool
+		mutations []string
+	}{
+		{name: "unarmed EIP is armed and skipped", remark: "web frontend", mutations: []string{"UpdateEIPAttribute eip-old01"}},
+		{name: "armed within the grace period waits", remark: armRemark("web frontend", time.Now().Add(-10*time.Minute))},
+		{name: "armed before the grace period rotates", remark: armRemark("web frontend", time.Now().Add(-2*time.Hour)), rotated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			e := boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01")
+			e.Remark = tt.remark
+			f.add("cn-bj2", "org-test", e)
+			task := testTask("org-test")
+			task.ArmGracePeriodSec = 3600
+
+			report, err := rotateOnce(context.Background(), task)
+			if err != nil {
+				t.Fatalf("rotateOnce: %v", err)
+			}
+			if tt.rotated {
+				if report.Rotated != 1 {
+					t.Errorf("report = %+v, want the host rotated", report)
+				}
+				return
+			}
+			if report.Rotated != 0 || len(report.Skipped) != 1 {
+				t.Errorf("report = %+v, want the host skipped", report)
+			}
+			if got := f.mutations(); !reflect.DeepEqual(got, tt.mutations) {
+				t.Errorf("mutations = %v, want %v", got, tt.mutations)
+			}
+			// arming keeps the remark the EIP had
+			old, _ := f.eip("eip-old01")
+			if at, ok := armedAt(old.Remark); !ok || !strings.HasPrefix(old.Remark, "web frontend ") || time.Since(at) > 3*time.Hour {
+				t.Errorf("remark = %q, want the original remark with an arm token", old.Remark)
+			}
+		})
+	}
+}
+
+func TestArmedAt(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		remark string
+		ok     bool
+	}{
+		{armRemark("", at), true},
+		{armRemark("web frontend", at), true},
+		{"web frontend", false},
+		{armMarkerPrefix + "soon", false},
+	}
+	for _, tt := range tests {
+		got, ok := armedAt(tt.remark)
+		if ok != tt.ok || (ok && !got.Equal(at)) {
+			t.Errorf("armedAt(%q) = %v, %v", tt.remark, got, ok)
+		}
+	}
+}
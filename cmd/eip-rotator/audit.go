@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditRecord is one line of --audit-log, written for every successful rotation
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Task      string    `json:"task"`
+	Region    string    `json:"region"`
+	ProjectID string    `json:"project_id"`
+	HostID    string    `json:"host_id"`
+	OldEIP    string    `json:"old_eip,omitempty"`
+	OldIP     string    `json:"old_ip,omitempty"`
+	NewEIP    string    `json:"new_eip"`
+	NewIP     string    `json:"new_ip"`
+	PublicKey string    `json:"public_key"`
+}
+
+// auditLog appends JSON lines to the --audit-log file; nil file means auditing is off
+var auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	auditLog.file = f
+	return nil
+}
+
+// audit writes, syncs and keeps one record; failures are logged and counted, never fail the rotation
+func audit(task taskConfig, b hostBinding, oldIPs []string, newEIP string, newIPs []string) {
+	rec := auditRecord{
+		Time:      time.Now().UTC(),
+		Task:      taskID(task),
+		Region:    b.Region,
+		ProjectID: b.ProjectID,
+		HostID:    b.UHostID,
+		OldEIP:    b.EIPID,
+		OldIP:     strings.Join(oldIPs, ","),
+		NewEIP:    newEIP,
+		NewIP:     strings.Join(newIPs, ","),
+		PublicKey: task.PublicKey,
+	}
+	control.rotated(rec)
+	if auditLog.file == nil {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err == nil {
+		auditLog.mu.Lock()
+		_, err = auditLog.file.Write(append(line, '\n'))
+		if err == nil {
+			err = auditLog.file.Sync()
+		}
+		auditLog.mu.Unlock()
+	}
+	if err != nil {
+		metrics.AddCounter("eip_rotator_audit_write_errors_total", "Rotations whose audit record could not be written", 1)
+		stdLogger().Errorf("AUDIT RECORD LOST for region=%s host=%s old=%s new=%s: %v", b.Region, b.UHostID, b.EIPID, newEIP, err)
+	}
+}
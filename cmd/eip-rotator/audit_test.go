@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+	f.fail = func(action string, p url.Values) (int, string) {
+		// the second host fails, so it gets no audit record
+		if action == "UnBindEIP" && p.Get("EIPId") == "eip-aaaa02" {
+			return 8000, "unbind refused"
+		}
+		return 0, ""
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := openAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { auditLog.file.Close(); auditLog.file = nil }()
+
+	if _, err := rotateOnce(context.Background(), testTask("org-test")); err == nil {
+		t.Fatal("rotateOnce succeeded, want the unbind failure")
+	}
+	lines := readLines(t, path)
+	if len(lines) != 1 {
+		t.Fatalf("audit log has %d lines, want one: %q", len(lines), lines)
+	}
+	var rec auditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	newEIP, _ := f.eip("eip-new1")
+	want := auditRecord{Time: rec.Time, Task: "test", Region: "cn-bj2", ProjectID: "org-test", HostID: "uhost-web01",
+		OldEIP: "eip-aaaa01", OldIP: "106.75.1.1", NewEIP: "eip-new1", NewIP: newEIP.EIPAddr[0].IP, PublicKey: "pub"}
+	if rec != want || rec.Time.IsZero() {
+		t.Errorf("audit record = %+v, want %+v", rec, want)
+	}
+}
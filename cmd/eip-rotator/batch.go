@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// batchSize is how many of n bindings one run rotates; 0 means all of them
+func (t taskConfig) batchSize(n int) int {
+	size := 0
+	switch {
+	case t.RotateBatch > 0:
+		size = t.RotateBatch
+	case t.RotateFraction > 0 && t.RotateFraction < 1:
+		size = int(math.Ceil(t.RotateFraction * float64(n)))
+	}
+	if size <= 0 || size >= n {
+		return 0
+	}
+	return size
+}
+
+// selectBatch picks this run's slice of bindings and advances the region's cursor, so successive
+// runs walk the whole fleet. Bindings are ordered by host first so the walk survives EIP id changes.
+func selectBatch(task taskConfig, region string, bindings []hostBinding) []hostBinding {
+	if task.batchSize(len(bindings)) == 0 {
+		return orderBindings(task, bindings)
+	}
+	batch, offset, next := peekBatch(task, region, bindings)
+	state.update(taskKey(task), func(ts *taskState) {
+		if ts.Cursor == nil {
+			ts.Cursor = map[string]int{}
+		}
+		ts.Cursor[region] = next
+	})
+	stdLogger().Infof("batch region=%s offset=%d size=%d of %d bindings, next offset=%d", region, offset, len(batch), len(bindings), next)
+	return batch
+}
+
+// peekBatch is selectBatch without moving the cursor; it also returns the batch's offset and the
+// cursor the next run would start from. Without batching the batch is all bindings. Either way it
+// comes back in the task's RotateOrder; which hosts a batch holds does not depend on that order.
+func peekBatch(task taskConfig, region string, bindings []hostBinding) (batch []hostBinding, offset, next int) {
+	size := task.batchSize(len(bindings))
+	if size == 0 {
+		return orderBindings(task, bindings), 0, 0
+	}
+	sorted := append([]hostBinding(nil), bindings...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].UHostID != sorted[j].UHostID {
+			return sorted[i].UHostID < sorted[j].UHostID
+		}
+		return sorted[i].EIPID < sorted[j].EIPID
+	})
+	offset = state.get(taskKey(task)).Cursor[region] % len(sorted)
+	batch = make([]hostBinding, 0, size)
+	for i := 0; i < size; i++ {
+		batch = append(batch, sorted[(offset+i)%len(sorted)])
+	}
+	return orderBindings(task, batch), offset, (offset + size) % len(sorted)
+}
+
+// rotate_order values; the default is discovery order (project by project, as DescribeEIP lists them)
+const (
+	rotateOrderDiscovery = "discovery"
+	rotateOrderNameAsc   = "name-asc"
+	rotateOrderNameDesc  = "name-desc"
+	rotateOrderEIPAge    = "eip-age"
+)
+
+func validRotateOrder(s string) bool {
+	switch s {
+	case "", rotateOrderDiscovery, rotateOrderNameAsc, rotateOrderNameDesc, rotateOrderEIPAge:
+		return true
+	}
+	return false
+}
+
+// orderBindings returns bindings in the order they are rotated: by host name (ties by host id, then
+// EIP id), oldest EIP first, or unchanged for discovery order
+func orderBindings(task taskConfig, bindings []hostBinding) []hostBinding {
+	var less func(a, b hostBinding) bool
+	switch task.RotateOrder {
+	case rotateOrderNameAsc, rotateOrderNameDesc:
+		desc := task.RotateOrder == rotateOrderNameDesc
+		less = func(a, b hostBinding) bool {
+			if a.UHostName != b.UHostName {
+				return (a.UHostName < b.UHostName) != desc
+			}
+			if a.UHostID != b.UHostID {
+				return a.UHostID < b.UHostID
+			}
+			return a.EIPID < b.EIPID
+		}
+	case rotateOrderEIPAge:
+		less = func(a, b hostBinding) bool {
+			if !a.EIPCreated.Equal(b.EIPCreated) {
+				return a.EIPCreated.Before(b.EIPCreated)
+			}
+			return a.EIPID < b.EIPID
+		}
+	default:
+		return bindings
+	}
+	sorted := append([]hostBinding(nil), bindings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBatchSize(t *testing.T) {
+	tests := []struct {
+		batch    int
+		fraction float64
+		n, want  int
+	}{
+		{n: 10, want: 0},
+		{batch: 3, n: 10, want: 3},
+		{batch: 10, n: 10, want: 0},
+		{batch: 3, fraction: 0.9, n: 10, want: 3},
+		{fraction: 0.25, n: 10, want: 3},
+		{fraction: 0.01, n: 10, want: 1},
+		{fraction: 1, n: 10, want: 0},
+	}
+	for _, tt := range tests {
+		task := taskConfig{RotateBatch: tt.batch, RotateFraction: tt.fraction}
+		if got := task.batchSize(tt.n); got != tt.want {
+			t.Errorf("batch=%d fraction=%v: batchSize(%d) = %d, want %d", tt.batch, tt.fraction, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSelectBatchWalksTheFleet(t *testing.T) {
+	prev := state
+	state = &stateStore{Tasks: map[string]*taskState{}}
+	defer func() { state = prev }()
+
+	var bindings []hostBinding
+	// discovery order differs from host order; batches follow host order
+	for _, h := range []string{"uhost-c", "uhost-a", "uhost-e", "uhost-b", "uhost-d"} {
+		bindings = append(bindings, hostBinding{Region: "cn-bj2", UHostID: h, EIPID: "eip-" + h})
+	}
+	task := taskConfig{Name: "web", RotateBatch: 2}
+	want := [][]string{
+		{"uhost-a", "uhost-b"},
+		{"uhost-c", "uhost-d"},
+		{"uhost-e", "uhost-a"},
+		{"uhost-b", "uhost-c"},
+	}
+	for run, w := range want {
+		if batch, _, _ := peekBatch(task, "cn-bj2", bindings); !reflect.DeepEqual(hostIDs(batch), w) {
+			t.Errorf("run %d: peekBatch = %v, want %v", run, hostIDs(batch), w)
+		}
+		if got := hostIDs(selectBatch(task, "cn-bj2", bindings)); !reflect.DeepEqual(got, w) {
+			t.Errorf("run %d: batch = %v, want %v", run, got, w)
+		}
+	}
+	// each region keeps its own cursor
+	if got := hostIDs(selectBatch(task, "cn-sh2", bindings)); !reflect.DeepEqual(got, want[0]) {
+		t.Errorf("first batch in another region = %v, want %v", got, want[0])
+	}
+}
+
+func hostIDs(bindings []hostBinding) []string {
+	var out []string
+	for _, b := range bindings {
+		out = append(out, b.UHostID)
+	}
+	return out
+}
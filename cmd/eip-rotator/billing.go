@@ -0,0 +1,114 @@
+package main
+
+import "fmt"
+
+// billing values accepted for new_pay_mode / new_charge_type overrides; ShareBandwidth is left out
+// because it also needs a shared bandwidth id the rotator does not know
+var (
+	payModes    = []string{"Bandwidth", "Traffic", "PostAccurateBandwidth"}
+	chargeTypes = []string{"Year", "Month", "Dynamic"}
+)
+
+// validateBilling checks one PayMode/ChargeType pair; traffic based modes are only sold hourly
+func validateBilling(payMode, chargeType string) error {
+	if payMode != "" && !containsString(payModes, payMode) {
+		return fmt.Errorf("unsupported pay mode %q (want one of %v)", payMode, payModes)
+	}
+	if chargeType != "" && !containsString(chargeTypes, chargeType) {
+		return fmt.Errorf("unsupported charge type %q (want one of %v)", chargeType, chargeTypes)
+	}
+	if (payMode == "Traffic" || payMode == "PostAccurateBandwidth") && chargeType != "" && chargeType != "Dynamic" {
+		return fmt.Errorf("pay mode %s requires charge type Dynamic, got %s", payMode, chargeType)
+	}
+	return nil
+}
+
+// billingFor returns the PayMode and ChargeType to allocate b's replacement with: the old EIP's,
+// unless the task overrides them
+func billingFor(task taskConfig, b hostBinding) (payMode, chargeType string, err error) {
+	payMode, chargeType = b.EIPPayMode, b.EIPChargeType
+	if task.NewPayMode != "" {
+		payMode = task.NewPayMode
+	}
+	if task.NewChargeType != "" {
+		chargeType = task.NewChargeType
+	}
+	if task.NewPayMode == "" && task.NewChargeType == "" {
+		return payMode, chargeType, nil
+	}
+	if err := validateBilling(payMode, chargeType); err != nil {
+		return "", "", err
+	}
+	if payMode != b.EIPPayMode || chargeType != b.EIPChargeType {
+		stdLogger().Infof("billing override region=%s host=%s(%s) eip=%s: pay_mode %s -> %s, charge_type %s -> %s", b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, b.EIPPayMode, payMode, b.EIPChargeType, chargeType)
+	}
+	return payMode, chargeType, nil
+}
+
+// tagFor returns the business group for b's replacement: new_tag when set, otherwise the old EIP's
+func tagFor(task taskConfig, b hostBinding) string {
+	if task.NewTag != "" {
+		return task.NewTag
+	}
+	return b.EIPTag
+}
+
+// bandwidthRange is the Mbps range AllocateEIP accepts for a pay mode (SDK documentation:
+// traffic 1-300, bandwidth 1-10000); shared bandwidth EIPs must be allocated with 0
+func bandwidthRange(payMode string) (min, max int) {
+	switch payMode {
+	case "ShareBandwidth":
+		return 0, 0
+	case "Traffic":
+		return 1, 300
+	default:
+		return 1, 10000
+	}
+}
+
+// bandwidthFor returns the bandwidth to allocate b's replacement with. Old EIPs can predate the
+// current limits, so an out of range value is clamped to the nearest allowed one (and logged),
+// or rejected when the task sets strict_bandwidth.
+func bandwidthFor(task taskConfig, b hostBinding, payMode string) (int, error) {
+	min, max := bandwidthRange(payMode)
+	bw := b.EIPBandwidth
+	switch {
+	case bw < min:
+		bw = min
+	case bw > max:
+		bw = max
+	default:
+		return bw, nil
+	}
+	if task.StrictBandwidth {
+		return 0, fmt.Errorf("bandwidth %dMbps is outside %d-%dMbps allowed for pay mode %s", b.EIPBandwidth, min, max, payMode)
+	}
+	stdLogger().Warnf("region=%s host=%s(%s) eip=%s: bandwidth %dMbps is outside %d-%dMbps for pay mode %s, allocating %dMbps", b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, b.EIPBandwidth, min, max, payMode, bw)
+	return bw, nil
+}
+
+// allocSpec is everything a replacement EIP is allocated with
+type allocSpec struct {
+	Operator   string
+	Bandwidth  int
+	PayMode    string
+	ChargeType string
+	Tag        string
+}
+
+// specFor works out the allocSpec for b's replacement from the old EIP and the task's overrides,
+// or takes it from force_spec when the task sets one
+func specFor(task taskConfig, b hostBinding) (allocSpec, error) {
+	if task.ForceSpec != nil {
+		return forcedSpec(task, b), nil
+	}
+	payMode, chargeType, err := billingFor(task, b)
+	if err != nil {
+		return allocSpec{}, err
+	}
+	bandwidth, err := bandwidthFor(task, b, payMode)
+	if err != nil {
+		return allocSpec{}, err
+	}
+	return allocSpec{Operator: operatorFor(task, b), Bandwidth: bandwidth, PayMode: payMode, ChargeType: chargeType, Tag: tagFor(task, b)}, nil
+}
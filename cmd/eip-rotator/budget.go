@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// maxRotationsPerRun (--max-rotations-per-run) caps how many EIPs one run rotates across all of its
+// regions and projects; in run mode the cap is shared by every task of the invocation. 0 = unlimited.
+var maxRotationsPerRun int
+
+// rotationBudget counts down the rotations left in a run; a nil budget is unlimited
+type rotationBudget struct {
+	mu   sync.Mutex
+	left int
+}
+
+type rotationBudgetKey struct{}
+
+// withRotationBudget starts a budget of --max-rotations-per-run for the run under ctx, unless ctx
+// already carries one from an enclosing run
+func withRotationBudget(ctx context.Context) context.Context {
+	if maxRotationsPerRun <= 0 || rotationBudgetFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rotationBudgetKey{}, &rotationBudget{left: maxRotationsPerRun})
+}
+
+func rotationBudgetFrom(ctx context.Context) *rotationBudget {
+	b, _ := ctx.Value(rotationBudgetKey{}).(*rotationBudget)
+	return b
+}
+
+// exhausted reports whether no rotations are left
+func (b *rotationBudget) exhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.left <= 0
+}
+
+// spend records one rotation
+func (b *rotationBudget) spend() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.left--
+}
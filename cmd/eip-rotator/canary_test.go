@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanaryFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		health   []string // nil records the canary's host
+		pause    int
+		timeout  time.Duration
+		rotated  int
+		errorHas string
+	}{
+		{name: "healthy canary lets the rest go", rotated: 2},
+		{name: "failed health check stops the region", health: []string{"false"}, rotated: 1, errorHas: "canary health check failed"},
+		{name: "the pause can be canceled", pause: 60, timeout: 500 * time.Millisecond, rotated: 1, errorHas: "canceled during canary pause"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+			task := testTask("org-test")
+			health, out := hookLog(t, "{{.HostID}}", "{{.NewEIP}}")
+			if tt.health != nil {
+				health = tt.health
+			}
+			task.CanaryFirst, task.CanaryHealthCmd, task.CanaryPauseSec = true, health, tt.pause
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			report, err := rotateOnce(ctx, task)
+			if report.Rotated != tt.rotated {
+				t.Errorf("report = %+v, want %d rotated", report, tt.rotated)
+			}
+			if tt.errorHas == "" {
+				if err != nil {
+					t.Fatalf("rotateOnce: %v", err)
+				}
+				// the health check runs once, for the canary only
+				if got, want := readLines(t, out), []string{"uhost-web01 eip-new1"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("health check ran with %q, want %q", got, want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorHas) {
+				t.Errorf("rotateOnce error = %v, want %q", err, tt.errorHas)
+			}
+			// the host after the canary is left alone
+			if got := f.boundTo("uhost-web02"); !reflect.DeepEqual(got, []string{"eip-aaaa02"}) {
+				t.Errorf("uhost-web02 bound to %v, want it untouched", got)
+			}
+		})
+	}
+}
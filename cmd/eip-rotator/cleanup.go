@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runCleanup lists leaked EIPs (allocated by us, never bound, older than minAge) and releases them when confirm is set
+func runCleanup(w io.Writer, tasks []taskConfig, confirm bool, minAge time.Duration) error {
+	ctx := context.Background()
+	lg := stdLogger()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tPROJECT\tEIP\tIPS\tTASK\tHOST\tAGE\tACTION")
+	var (
+		candidates, released int
+		firstErr             error
+		seen                 = map[string]bool{} // tasks sharing a project see the same EIPs
+		pending              = state.pendingIDs()
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		lg.Errorf("cleanup: %v", err)
+	}
+	for _, t := range tasks {
+		if err := checkRegionGuard(t); err != nil {
+			fail(fmt.Errorf("task %s: %w", taskLabel(t), err))
+			continue
+		}
+		ctx := withTask(ctx, t)
+		discoverCred := newDiscoverCredential(t)
+		regions, err := resolveRegions(ctx, t, discoverCred)
+		if err == nil {
+			t, err = expandProjects(ctx, t, discoverCred)
+		}
+		if err != nil {
+			fail(fmt.Errorf("task %s: %w", taskLabel(t), err))
+			continue
+		}
+		credential := newCredential(t)
+		for _, region := range regions {
+			_, orphans, _, err := discoverBindings(newUNetClient(ctx, discoverCred, region), t, region)
+			if err != nil {
+				fail(fmt.Errorf("region=%s: %w", region, err))
+				continue
+			}
+			client := newUNetClient(ctx, credential, region)
+			for _, o := range orphans {
+				if seen[o.EIPID] {
+					continue
+				}
+				if o.Stash {
+					// not ours: only collected for prefer_existing_eip
+					continue
+				}
+				if o.Pool {
+					// a standby pool, possibly of a task not in this config
+					continue
+				}
+				if pending[o.EIPID] {
+					// an old EIP a task releases itself on its next run
+					continue
+				}
+				seen[o.EIPID] = true
+				age := time.Since(o.Created)
+				if age < minAge {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", region, o.ProjectID, o.EIPID, strings.Join(o.IPs, ","), o.TaskID, o.HostID, age.Round(time.Minute), "too young, skipped")
+					continue
+				}
+				candidates++
+				action := "would release"
+				if confirm {
+					if err := releaseEIP(client, o.ProjectID, o.EIPID); err != nil {
+						fail(fmt.Errorf("region=%s ReleaseEIP %s: %w", region, o.EIPID, err))
+						action = "release failed"
+					} else {
+						released++
+						action = "released"
+					}
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", region, o.ProjectID, o.EIPID, strings.Join(o.IPs, ","), o.TaskID, o.HostID, age.Round(time.Minute), action)
+			}
+		}
+	}
+	if confirm {
+		fmt.Fprintf(tw, "TOTAL\t\t%d candidates, %d released\n", candidates, released)
+	} else {
+		fmt.Fprintf(tw, "TOTAL\t\t%d candidates (dry run, add --confirm to release)\n", candidates)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return firstErr
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanupReleasesOnlyLeakedEIPs(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	f.add("cn-bj2", "org-test", boundEIP("eip-web01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", unboundEIP("eip-leaked", "117.50.1.1", allocationMarker(task, "uhost-web02")))
+	young := unboundEIP("eip-young", "117.50.1.2", allocationMarker(task, "uhost-web03"))
+	young.CreateTime = int(time.Now().Add(-5 * time.Minute).Unix())
+	f.add("cn-bj2", "org-test", young)
+	f.add("cn-bj2", "org-test", unboundEIP("eip-otherpool", "117.50.1.3", "eip-rotator-pool:other"))
+	f.add("cn-bj2", "org-test", unboundEIP("eip-kept", "117.50.1.4", keptMarker(task)))
+	f.add("cn-bj2", "org-test", unboundEIP("eip-pending", "117.50.1.5", "eip-rotator:other:uhost-web04"))
+	state.update("another-task", func(ts *taskState) {
+		ts.PendingRelease = append(ts.PendingRelease, pendingRelease{Region: "cn-bj2", ProjectID: "org-test", EIPID: "eip-pending", Since: time.Now()})
+	})
+
+	var out strings.Builder
+	if err := runCleanup(&out, []taskConfig{task}, false, time.Hour); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if got := f.callsOf("ReleaseEIP"); len(got) != 0 {
+		t.Fatalf("dry run released %v", got)
+	}
+	if !strings.Contains(out.String(), "1 candidates (dry run") {
+		t.Errorf("dry run output:\n%s\nwant 1 candidate", out.String())
+	}
+
+	out.Reset()
+	if err := runCleanup(&out, []taskConfig{task}, true, time.Hour); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if got := f.callsOf("ReleaseEIP"); len(got) != 1 || got[0] != "ReleaseEIP eip-leaked" {
+		t.Errorf("released %v, want only eip-leaked", got)
+	}
+	if !strings.Contains(out.String(), "too young, skipped") {
+		t.Errorf("output:\n%s\nwant eip-young listed as too young", out.String())
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configExts are the task file formats loadTasks understands
+var configExts = map[string]bool{".json": true, ".toml": true}
+
+// taskKey keys a task in the scheduler and the state file: its name when set, otherwise credentials
+// and project_ids as written (before "all" is expanded); state is never moved between keys
+func taskKey(t taskConfig) string {
+	if t.Name != "" {
+		return fmt.Sprintf("%x", sha1Bytes([]byte("name|"+t.Name)))
+	}
+	projects := t.Projects
+	if t.ProjectSpec != nil {
+		projects = t.ProjectSpec
+	}
+	key := fmt.Sprintf("%s|%s|%s", strings.TrimSpace(t.PublicKey), strings.TrimSpace(t.PrivateKey), strings.Join(projects, ","))
+	return fmt.Sprintf("%x", sha1Bytes([]byte(key)))
+}
+
+// defaultProfile holds the tasks of files written without profiles (a plain task list)
+const defaultProfile = "default"
+
+// configProfile (--profile) selects which profile loadTasks returns
+var configProfile = defaultProfile
+
+// loadTasks reads the configProfile task list from a file, or from every .json/.toml file in a
+// directory (sorted by name), de-duplicated by configIdentity; the first definition wins
+func loadTasks(path string) ([]taskConfig, error) {
+	files, err := configFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []taskConfig
+	type origin struct {
+		index int
+		file  string
+	}
+	seen := map[string]origin{}
+	found := false
+	var available []string
+	for _, f := range files {
+		profiles, err := loadTaskFile(f)
+		if err != nil {
+			return nil, err
+		}
+		fileTasks, ok := profiles[configProfile]
+		if !ok {
+			for name := range profiles {
+				if !containsString(available, name) {
+					available = append(available, name)
+				}
+			}
+			continue
+		}
+		found = true
+		for _, t := range fileTasks {
+			if err := applyCredentialProfile(&t); err != nil {
+				return nil, fmt.Errorf("config %s: %w", f, err)
+			}
+			id := configIdentity(t)
+			if o, dup := seen[id]; dup {
+				if !reflect.DeepEqual(tasks[o.index], t) {
+					stdLogger().Errorf("config conflict: task %s in %s differs from the one in %s, keeping the first", taskLabel(t), f, o.file)
+				}
+				continue
+			}
+			seen[id] = origin{index: len(tasks), file: f}
+			tasks = append(tasks, t)
+		}
+	}
+	if !found && len(files) > 0 {
+		sort.Strings(available)
+		return nil, fmt.Errorf("config %s: profile %q not found (available: %s)", path, configProfile, strings.Join(available, ","))
+	}
+	for i := range tasks {
+		if err := applyEnvOverrides(&tasks[i]); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return tasks, nil
+}
+
+// configIdentity is what loadTasks de-duplicates by: the name, or for an unnamed task its key and
+// regions, since the key alone does not tell apart the same projects in different regions
+func configIdentity(t taskConfig) string {
+	if t.Name != "" {
+		return "name|" + t.Name
+	}
+	return fmt.Sprintf("%s|%s|%s|%t", taskKey(t), strings.TrimSpace(t.Region), strings.Join(t.Regions, ","), t.AllRegions)
+}
+
+// configFiles expands path to the config files it stands for
+func configFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config dir: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !configExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		files = append(files, filepath.Join(path, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadTaskFile reads one .json or .toml task file into task lists by profile; tasks are decoded
+// over the file's (or profile's) defaults block, see the README for the layouts
+func loadTaskFile(path string) (map[string][]taskConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var profiles map[string][]taskConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		profiles, err = decodeJSONProfiles(b)
+	case ".toml":
+		profiles, err = decodeTOMLProfiles(b)
+	default:
+		return nil, fmt.Errorf("unsupported config extension %q (want .json or .toml)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// isProfileMap reports whether the top-level keys of a file name profiles rather than a single
+// task list; defaults and tasks are reserved for the latter
+func isProfileMap(keys []string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	for _, k := range keys {
+		if k == "defaults" || k == "tasks" {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeJSONProfiles(b []byte) (map[string][]taskConfig, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		tasks, err := decodeJSONTasks(b)
+		return map[string][]taskConfig{defaultProfile: tasks}, err
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(top))
+	for k := range top {
+		keys = append(keys, k)
+	}
+	if !isProfileMap(keys) {
+		tasks, err := decodeJSONTasks(b)
+		return map[string][]taskConfig{defaultProfile: tasks}, err
+	}
+	profiles := make(map[string][]taskConfig, len(top))
+	for name, raw := range top {
+		tasks, err := decodeJSONTasks(raw)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		profiles[name] = tasks
+	}
+	return profiles, nil
+}
+
+// decodeJSONTasks accepts a plain task array or an object with defaults and tasks
+func decodeJSONTasks(b []byte) ([]taskConfig, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 || trimmed[0] == '[' {
+		var tasks []taskConfig
+		err := json.Unmarshal(b, &tasks)
+		return tasks, err
+	}
+	var doc struct {
+		Defaults json.RawMessage   `json:"defaults"`
+		Tasks    []json.RawMessage `json:"tasks"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	defaults, err := decodeDefaults(func(d *taskConfig) error {
+		if len(doc.Defaults) == 0 {
+			return nil
+		}
+		return json.Unmarshal(doc.Defaults, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]taskConfig, 0, len(doc.Tasks))
+	for i, raw := range doc.Tasks {
+		t := defaults()
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("task #%d: %w", i, err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// tomlTaskList is a task list with an optional defaults block, as a whole file or as one profile
+type tomlTaskList struct {
+	Defaults toml.Primitive   `toml:"defaults"`
+	Tasks    []toml.Primitive `toml:"tasks"`
+}
+
+func decodeTOMLProfiles(b []byte) (map[string][]taskConfig, error) {
+	var top map[string]toml.Primitive
+	md, err := toml.Decode(string(b), &top)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(top))
+	for k := range top {
+		keys = append(keys, k)
+	}
+	if !isProfileMap(keys) {
+		doc := tomlTaskList{Defaults: top["defaults"]}
+		if p, ok := top["tasks"]; ok {
+			if err := md.PrimitiveDecode(p, &doc.Tasks); err != nil {
+				return nil, err
+			}
+		}
+		tasks, err := decodeTOMLTasks(md, doc)
+		return map[string][]taskConfig{defaultProfile: tasks}, err
+	}
+	profiles := make(map[string][]taskConfig, len(top))
+	for name, p := range top {
+		var doc tomlTaskList
+		var err error
+		if md.Type(name) == "ArrayHash" {
+			// [[prod]]: the profile is the task list itself
+			err = md.PrimitiveDecode(p, &doc.Tasks)
+		} else {
+			err = md.PrimitiveDecode(p, &doc)
+		}
+		if err == nil {
+			profiles[name], err = decodeTOMLTasks(md, doc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return profiles, nil
+}
+
+func decodeTOMLTasks(md toml.MetaData, doc tomlTaskList) ([]taskConfig, error) {
+	defaults, err := decodeDefaults(func(d *taskConfig) error {
+		return md.PrimitiveDecode(doc.Defaults, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]taskConfig, 0, len(doc.Tasks))
+	for i, p := range doc.Tasks {
+		t := defaults()
+		if err := md.PrimitiveDecode(p, &t); err != nil {
+			return nil, fmt.Errorf("task #%d: %w", i, err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// decodeDefaults decodes the defaults block and returns a constructor for fresh copies of it.
+// Copies go through JSON so tasks never share slices or pointers with the defaults (or each other)
+// when decoded over them.
+func decodeDefaults(decode func(*taskConfig) error) (func() taskConfig, error) {
+	var d taskConfig
+	if err := decode(&d); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if d.Name != "" {
+		return nil, errors.New("defaults: name must be set per task")
+	}
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return func() taskConfig {
+		var t taskConfig
+		_ = json.Unmarshal(raw, &t)
+		return t
+	}, nil
+}
+
+// configStamp changes whenever the config file, or any config file in the directory, is added,
+// removed or modified; the scheduler polls it to trigger reloads
+func configStamp(path string) (string, error) {
+	files, err := configFiles(path)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s|%d|%d;", f, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return sb.String(), nil
+}
+
+// taskLabel names a task for log lines without exposing credentials
+func taskLabel(t taskConfig) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("projects=%v", t.Projects)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfigDir writes files (name -> content) into a fresh directory and returns it
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadTasksDirectoryDeduplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		regions []string // the regions of the tasks kept, in load order
+	}{
+		{
+			name: "unnamed tasks in different regions are both kept",
+			files: map[string]string{
+				"a.json": `[{"public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-bj2"}]`,
+				"b.json": `[{"public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-sh2"}]`,
+			},
+			regions: []string{"cn-bj2", "cn-sh2"},
+		},
+		{
+			name: "the same unnamed task in two files is kept once",
+			files: map[string]string{
+				"a.json": `[{"public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-bj2"}]`,
+				"b.json": `[{"public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-bj2"}]`,
+			},
+			regions: []string{"cn-bj2"},
+		},
+		{
+			name: "a named task defined twice keeps the first",
+			files: map[string]string{
+				"a.json": `[{"name":"web","public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-bj2"}]`,
+				"b.json": `[{"name":"web","public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-sh2"}]`,
+			},
+			regions: []string{"cn-bj2"},
+		},
+		{
+			name: "json and toml files are merged",
+			files: map[string]string{
+				"a.json":    `[{"name":"web","public_key":"pub","private_key":"priv","project_ids":["org-a"],"region":"cn-bj2"}]`,
+				"b.toml":    "[[tasks]]\nname = \"db\"\npublic_key = \"pub\"\nprivate_key = \"priv\"\nproject_ids = [\"org-a\"]\nregion = \"hk\"\n",
+				"notes.txt": "ignored",
+			},
+			regions: []string{"cn-bj2", "hk"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := loadTasks(writeConfigDir(t, tt.files))
+			if err != nil {
+				t.Fatalf("loadTasks: %v", err)
+			}
+			var got []string
+			for _, task := range tasks {
+				got = append(got, task.Region)
+			}
+			if !reflect.DeepEqual(got, tt.regions) {
+				t.Errorf("kept tasks in %v, want %v", got, tt.regions)
+			}
+		})
+	}
+}
+
+func TestLoadTasksTOMLMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		json, toml string
+	}{
+		{
+			name: "plain task list",
+			json: `[{"name":"web","public_key":"pub","private_key":"priv","project_ids":["org-a","org-b"],"region":"cn-bj2","interval_sec":600}]`,
+			toml: "[[tasks]]\nname = \"web\"\npublic_key = \"pub\"\nprivate_key = \"priv\"\nproject_ids = [\"org-a\", \"org-b\"]\nregion = \"cn-bj2\"\ninterval_sec = 600\n",
+		},
+		{
+			name: "lists, pointers and durations",
+			json: `[{"name":"web","project_ids":["org-a"],"region":"cn-bj2","interval":"30m","run_on_start":false,"post_hook_cmd":["notify","{{.NewIP}}"]}]`,
+			toml: "[[tasks]]\nname = \"web\"\nproject_ids = [\"org-a\"]\nregion = \"cn-bj2\"\ninterval = \"30m\"\nrun_on_start = false\npost_hook_cmd = [\"notify\", \"{{.NewIP}}\"]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigDir(t, map[string]string{"tasks.json": tt.json, "tasks.toml": tt.toml})
+			fromJSON, err := loadTasks(filepath.Join(dir, "tasks.json"))
+			if err != nil {
+				t.Fatalf("json: %v", err)
+			}
+			fromTOML, err := loadTasks(filepath.Join(dir, "tasks.toml"))
+			if err != nil {
+				t.Fatalf("toml: %v", err)
+			}
+			if !reflect.DeepEqual(fromTOML, fromJSON) {
+				t.Errorf("toml tasks:\n %+v\njson tasks:\n %+v", fromTOML, fromJSON)
+			}
+		})
+	}
+}
+
+func TestLoadTasksExampleConfigs(t *testing.T) {
+	fromJSON, err := loadTasks("../../configs/tasks.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTOML, err := loadTasks("../../configs/tasks.example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fromJSON) == 0 || !reflect.DeepEqual(fromTOML, fromJSON) {
+		t.Errorf("the example configs differ:\n toml %+v\n json %+v", fromTOML, fromJSON)
+	}
+}
+
+func TestLoadTasksRejectsBadFiles(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"tasks.yaml": "- name: web\n",
+		"bad.toml":   "[[tasks]]\nname = \n",
+	})
+	for _, name := range []string{"tasks.yaml", "bad.toml"} {
+		if _, err := loadTasks(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s loaded, want an error", name)
+		}
+	}
+}
+
+func TestLoadTasksDefaults(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+	}{
+		{
+			name: "json",
+			file: "tasks.json",
+			content: `{"defaults":{"public_key":"pub","private_key":"priv","region":"cn-bj2","interval_sec":600,"post_hook_cmd":["notify"]},
+				"tasks":[{"name":"web","project_ids":["org-a"]},{"name":"db","project_ids":["org-b"],"region":"hk","post_hook_cmd":["page","{{.NewIP}}"]},{"name":"cache","project_ids":["org-c"]}]}`,
+		},
+		{
+			name: "toml",
+			file: "tasks.toml",
+			content: "[defaults]\npublic_key = \"pub\"\nprivate_key = \"priv\"\nregion = \"cn-bj2\"\ninterval_sec = 600\npost_hook_cmd = [\"notify\"]\n" +
+				"[[tasks]]\nname = \"web\"\nproject_ids = [\"org-a\"]\n" +
+				"[[tasks]]\nname = \"db\"\nproject_ids = [\"org-b\"]\nregion = \"hk\"\npost_hook_cmd = [\"page\", \"{{.NewIP}}\"]\n" +
+				"[[tasks]]\nname = \"cache\"\nproject_ids = [\"org-c\"]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := loadTasks(filepath.Join(writeConfigDir(t, map[string]string{tt.file: tt.content}), tt.file))
+			if err != nil {
+				t.Fatalf("loadTasks: %v", err)
+			}
+			if len(tasks) != 3 {
+				t.Fatalf("got %d tasks, want 3", len(tasks))
+			}
+			web, db := tasks[0], tasks[1]
+			if web.PublicKey != "pub" || web.Region != "cn-bj2" || web.Interval != 600 || !reflect.DeepEqual(web.PostHookCmd, []string{"notify"}) {
+				t.Errorf("web = %+v, want the defaults filled in", web)
+			}
+			// a list set in the task replaces the default one instead of extending it
+			if db.Region != "hk" || db.Interval != 600 || !reflect.DeepEqual(db.PostHookCmd, []string{"page", "{{.NewIP}}"}) {
+				t.Errorf("db = %+v, want its own region and hook over the defaults", db)
+			}
+			// tasks get their own copy of the defaults
+			web.PostHookCmd[0] = "changed"
+			if tasks[2].PostHookCmd[0] != "notify" {
+				t.Error("tasks share the default hook slice")
+			}
+		})
+	}
+}
+
+func TestLoadTasksRejectsNameInDefaults(t *testing.T) {
+	files := map[string]string{
+		"tasks.json": `{"defaults":{"name":"web"},"tasks":[{"project_ids":["org-a"]}]}`,
+		"tasks.toml": "[defaults]\nname = \"web\"\n[[tasks]]\nproject_ids = [\"org-a\"]\n",
+	}
+	dir := writeConfigDir(t, files)
+	for name := range files {
+		if _, err := loadTasks(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: name in defaults was accepted", name)
+		}
+	}
+}
+
+func TestLoadTasksProfiles(t *testing.T) {
+	files := map[string]string{
+		"plain.json": `[{"name":"web","project_ids":["org-a"],"region":"cn-bj2"}]`,
+		"profiles.json": `{"prod":[{"name":"web","project_ids":["org-a"],"region":"cn-bj2"}],
+			"staging":{"defaults":{"region":"cn-sh2"},"tasks":[{"name":"web","project_ids":["org-s"]}]}}`,
+		"profiles.toml": "[[prod]]\nname = \"web\"\nproject_ids = [\"org-a\"]\nregion = \"cn-bj2\"\n" +
+			"[staging.defaults]\nregion = \"cn-sh2\"\n[[staging.tasks]]\nname = \"web\"\nproject_ids = [\"org-s\"]\n",
+	}
+	dir := writeConfigDir(t, files)
+	tests := []struct {
+		file, profile string
+		region        string // the region of the single task loaded; "" wants an error
+	}{
+		{"plain.json", defaultProfile, "cn-bj2"},
+		{"plain.json", "prod", ""},
+		{"profiles.json", "prod", "cn-bj2"},
+		{"profiles.json", "staging", "cn-sh2"},
+		{"profiles.json", defaultProfile, ""},
+		{"profiles.toml", "prod", "cn-bj2"},
+		{"profiles.toml", "staging", "cn-sh2"},
+		{"profiles.toml", "qa", ""},
+	}
+	defer func(p string) { configProfile = p }(configProfile)
+	for _, tt := range tests {
+		configProfile = tt.profile
+		tasks, err := loadTasks(filepath.Join(dir, tt.file))
+		if tt.region == "" {
+			if err == nil {
+				t.Errorf("%s --profile %s: loaded %+v, want an error", tt.file, tt.profile, tasks)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s --profile %s: %v", tt.file, tt.profile, err)
+			continue
+		}
+		if len(tasks) != 1 || tasks[0].Region != tt.region {
+			t.Errorf("%s --profile %s: got %+v, want one task in %s", tt.file, tt.profile, tasks, tt.region)
+		}
+	}
+}
+
+func TestLoadTasksMissingProfileListsAvailable(t *testing.T) {
+	defer func(p string) { configProfile = p }(configProfile)
+	configProfile = "qa"
+	dir := writeConfigDir(t, map[string]string{"a.json": `{"prod":[],"staging":[]}`})
+	_, err := loadTasks(dir)
+	if err == nil || !strings.Contains(err.Error(), "available: prod,staging") {
+		t.Errorf("error = %v, want the available profiles listed", err)
+	}
+}
+
+// TestConfigStamp covers what a SIGHUP reload records so the poll does not reload the same change
+// again: the stamp holds still until a config file is edited, added or removed
+func TestConfigStamp(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"a.json": `[]`})
+	stamp := func(path string) string {
+		t.Helper()
+		s, err := configStamp(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	steps := []struct {
+		name   string
+		change func()
+		moves  bool
+	}{
+		{name: "nothing", change: func() {}},
+		{name: "unrelated file added", change: func() { os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o600) }},
+		{name: "file edited", change: func() { os.WriteFile(filepath.Join(dir, "a.json"), []byte(`[ ]`), 0o600) }, moves: true},
+		{name: "file added", change: func() { os.WriteFile(filepath.Join(dir, "b.toml"), nil, 0o600) }, moves: true},
+		{name: "file removed", change: func() { os.Remove(filepath.Join(dir, "b.toml")) }, moves: true},
+	}
+	last := stamp(dir)
+	for _, s := range steps {
+		s.change()
+		now := stamp(dir)
+		if moved := now != last; moved != s.moves {
+			t.Errorf("%s: stamp moved = %v, want %v", s.name, moved, s.moves)
+		}
+		last = now
+	}
+	if _, err := configStamp(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("stamp of a missing config succeeded")
+	}
+}
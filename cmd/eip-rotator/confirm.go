@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// confirmRotation, when set (run mode with --interactive on a terminal), is asked after discovery
+// in each region before anything is changed there
+var confirmRotation func(region string, bindings []hostBinding) bool
+
+// stdinIsTerminal reports whether stdin is a character device, i.e. a human could answer a prompt
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// promptConfirm lists the bindings on w and reads a y/N answer from r
+func promptConfirm(r *bufio.Reader, w io.Writer) func(string, []hostBinding) bool {
+	return func(region string, bindings []hostBinding) bool {
+		fmt.Fprintf(w, "region %s: %d EIPs will be rotated\n", region, len(bindings))
+		for _, b := range bindings {
+			fmt.Fprintf(w, "  %s\t%s(%s)\t%s\t%s\n", b.ProjectID, safeName(b.UHostName), b.UHostID, b.EIPID, strings.Join(b.EIPIPs, ","))
+		}
+		fmt.Fprint(w, "proceed? [y/N] ")
+		answer, _ := r.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
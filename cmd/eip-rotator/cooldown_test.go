@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastAgo  time.Duration // 0: never rotated
+		interval int
+		rotated  int
+	}{
+		{name: "rotated within the window is skipped", lastAgo: 10 * time.Minute, interval: 3600, rotated: 1},
+		{name: "rotated before the window is rotated", lastAgo: 2 * time.Hour, interval: 3600, rotated: 2},
+		{name: "never rotated is rotated", interval: 3600, rotated: 2},
+		{name: "no cooldown configured", lastAgo: time.Minute, rotated: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+			if tt.lastAgo > 0 {
+				state.markRotated("uhost-web01", time.Now().Add(-tt.lastAgo))
+			}
+			task := testTask("org-test")
+			task.MinRotationIntervalSec = tt.interval
+
+			report, err := rotateOnce(context.Background(), task)
+			if err != nil {
+				t.Fatalf("rotateOnce: %v", err)
+			}
+			if report.Rotated != tt.rotated {
+				t.Errorf("report = %+v, want %d rotated", report, tt.rotated)
+			}
+			if tt.rotated == 1 && (len(report.Skipped) != 1 || report.Skipped[0].UHostID != "uhost-web01" || !strings.Contains(report.Skipped[0].Reason, "in cooldown")) {
+				t.Errorf("skipped = %+v, want uhost-web01 in cooldown", report.Skipped)
+			}
+		})
+	}
+}
+
+func TestCooldownLetsAHostFinishItsOtherEIPs(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-line1", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-line2", "106.75.1.2", "uhost", "uhost-web01", "web-01"))
+	task := testTask("org-test")
+	task.MinRotationIntervalSec = 3600
+
+	report, err := rotateOnce(context.Background(), task)
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	// the cooldown that starts with the first EIP does not hold back the second one
+	if report.Rotated != 2 {
+		t.Errorf("report = %+v, want both EIPs rotated", report)
+	}
+	if _, ok := state.lastRotated("uhost-web01"); !ok {
+		t.Error("the rotation was not recorded for the cooldown")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// countFormats are the --format values of --mode count
+var countFormats = map[string]bool{"table": true, "json": true, "csv": true}
+
+// countRow is one EIP the count would rotate, as written by --format json/csv
+type countRow struct {
+	Region     string `json:"region"`
+	ProjectID  string `json:"project_id"`
+	HostID     string `json:"host_id"`
+	HostName   string `json:"host_name"`
+	EIPID      string `json:"eip_id"`
+	IP         string `json:"ip"` // every address of the EIP, comma separated
+	Bandwidth  int    `json:"bandwidth"`
+	Operator   string `json:"operator"`
+	PayMode    string `json:"pay_mode"`
+	ChargeType string `json:"charge_type"`
+}
+
+var countCSVHeader = []string{"region", "project", "host_id", "host_name", "eip_id", "ip", "bandwidth", "operator", "pay_mode", "charge_type"}
+
+// runCount runs discovery only and prints what each region/project would rotate: a per-project
+// count for format table, or one row per EIP for json and csv (a rotation plan for spreadsheets)
+func runCount(w io.Writer, tasks []taskConfig, format string) error {
+	if !countFormats[format] {
+		return fmt.Errorf("invalid --format %q (want table, json or csv)", format)
+	}
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if format == "table" {
+		fmt.Fprintln(tw, "REGION\tPROJECT\tEIPS")
+	}
+	var (
+		total    int
+		rows     []countRow
+		firstErr error
+	)
+	for _, t := range tasks {
+		ctx := withTask(ctx, t)
+		credential := newDiscoverCredential(t)
+		regions, err := resolveRegions(ctx, t, credential)
+		if err == nil {
+			t, err = expandProjects(ctx, t, credential)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			stdLogger().Errorf("count (projects=%v): %v", t.Projects, err)
+			continue
+		}
+		for _, region := range regions {
+			bindings, _, _, err := discoverBindings(newUNetClient(ctx, credential, region), t, region)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				stdLogger().Errorf("count region=%s: %v", region, err)
+				continue
+			}
+			perProject := map[string]int{}
+			for _, b := range bindings {
+				perProject[b.ProjectID]++
+				rows = append(rows, countRow{
+					Region:     b.Region,
+					ProjectID:  b.ProjectID,
+					HostID:     b.UHostID,
+					HostName:   b.UHostName,
+					EIPID:      b.EIPID,
+					IP:         strings.Join(b.EIPIPs, ","),
+					Bandwidth:  b.EIPBandwidth,
+					Operator:   b.EIPOperator,
+					PayMode:    b.EIPPayMode,
+					ChargeType: b.EIPChargeType,
+				})
+			}
+			if format != "table" {
+				continue
+			}
+			for _, project := range t.Projects {
+				fmt.Fprintf(tw, "%s\t%s\t%d\n", region, project, perProject[project])
+				total += perProject[project]
+			}
+		}
+	}
+	var err error
+	switch format {
+	case "table":
+		fmt.Fprintf(tw, "TOTAL\t\t%d\n", total)
+		err = tw.Flush()
+	case "json":
+		if rows == nil {
+			rows = []countRow{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rows)
+	case "csv":
+		err = writeCountCSV(w, rows)
+	}
+	if err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// writeCountCSV writes rows with a header line; encoding/csv quotes fields containing commas
+// (multi-address EIPs), quotes or newlines
+func writeCountCSV(w io.Writer, rows []countRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(countCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		rec := []string{r.Region, r.ProjectID, r.HostID, r.HostName, r.EIPID, r.IP, strconv.Itoa(r.Bandwidth), r.Operator, r.PayMode, r.ChargeType}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
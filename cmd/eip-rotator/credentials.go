@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sharedCredential is one entry of the shared credential file written by the UCloud CLI
+// (~/.ucloud/credential.json): a JSON array of {"profile", "public_key", "private_key"}
+type sharedCredential struct {
+	Profile    string `json:"profile"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// credentialsFile is --credentials-file, the shared credential file credential_profile is looked up in
+var credentialsFile string
+
+// defaultCredentialsFile is where the UCloud CLI keeps its credentials
+func defaultCredentialsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ucloud", "credential.json")
+}
+
+// loadCredentialProfile returns the keys of profile name from credentialsFile
+func loadCredentialProfile(name string) (publicKey, privateKey string, err error) {
+	if credentialsFile == "" {
+		return "", "", fmt.Errorf("credential profile %q: no credentials file (set --credentials-file)", name)
+	}
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return "", "", fmt.Errorf("credential profile %q: %w", name, err)
+	}
+	var entries []sharedCredential
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return "", "", fmt.Errorf("credentials file %s: %w", credentialsFile, err)
+	}
+	var available []string
+	for _, e := range entries {
+		if e.Profile != name {
+			available = append(available, e.Profile)
+			continue
+		}
+		if strings.TrimSpace(e.PublicKey) == "" || strings.TrimSpace(e.PrivateKey) == "" {
+			return "", "", fmt.Errorf("credentials file %s: profile %q has no public_key or private_key", credentialsFile, name)
+		}
+		return strings.TrimSpace(e.PublicKey), strings.TrimSpace(e.PrivateKey), nil
+	}
+	sort.Strings(available)
+	return "", "", fmt.Errorf("credentials file %s: profile %q not found (available: %s)", credentialsFile, name, strings.Join(available, ","))
+}
+
+// applyCredentialProfile fills the task's keys from its credential_profile. The file is read on
+// every config load, so a reload picks up rotated keys.
+func applyCredentialProfile(t *taskConfig) error {
+	if t.CredentialProfile == "" {
+		return nil
+	}
+	if t.PublicKey != "" || t.PrivateKey != "" {
+		return fmt.Errorf("task %s: credential_profile cannot be combined with public_key/private_key", taskLabel(*t))
+	}
+	pub, priv, err := loadCredentialProfile(t.CredentialProfile)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", taskLabel(*t), err)
+	}
+	t.PublicKey, t.PrivateKey = pub, priv
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// unbindOnClaim is a fail hook that unbinds eipID, as someone else might, as soon as a replacement
+// is claimed, so the stale-binding recheck skips the host
+func unbindOnClaim(f *fakeUCloud, eipID string) func(string, url.Values) (int, string) {
+	return func(action string, p url.Values) (int, string) {
+		if e, ok := f.eips[eipID]; ok && action == "UpdateEIPAttribute" {
+			e.Status, e.Resource = "free", unet.UnetEIPResourceSet{}
+		}
+		return 0, ""
+	}
+}
+
+func TestStaleBindingReturnsPoolEIP(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	task.PoolSize = 1
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", unboundEIP("eip-pool01", "117.50.1.1", poolMarker(task)))
+	f.fail = unbindOnClaim(f, "eip-old01")
+
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	e, ok := f.eip("eip-pool01")
+	if !ok {
+		t.Fatal("the unused pool EIP was released")
+	}
+	if e.Remark != poolMarker(task) {
+		t.Errorf("pool EIP remark = %q, want %q", e.Remark, poolMarker(task))
+	}
+	// it is back in the pool, so the pool needs no new member
+	if got := f.callsOf("AllocateEIP"); len(got) != 0 {
+		t.Errorf("allocated %v, want the pool left at its size", got)
+	}
+}
+
+func TestStaleBindingReturnsStashEIP(t *testing.T) {
+	for _, remark := range []string{"spare for the office VPN", ""} {
+		f := newFakeUCloud(t)
+		task := testTask("org-test")
+		task.PreferExistingEIP = true
+		f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+		f.add("cn-bj2", "org-test", unboundEIP("eip-spare", "117.50.1.1", remark))
+		f.fail = unbindOnClaim(f, "eip-old01")
+
+		if _, err := rotateOnce(context.Background(), task); err != nil {
+			t.Fatalf("rotateOnce: %v", err)
+		}
+		e, ok := f.eip("eip-spare")
+		if !ok {
+			t.Fatalf("remark %q: the unused existing EIP was released", remark)
+		}
+		if e.Remark != remark {
+			t.Errorf("existing EIP remark = %q, want %q restored", e.Remark, remark)
+		}
+	}
+}
+
+func TestRetryReleasesOnlyAllocatedEIP(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	task.PoolSize = 1
+	task.PerHostRetries = 1
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", unboundEIP("eip-pool01", "117.50.1.1", poolMarker(task)))
+	binds := 0
+	f.fail = func(action string, p url.Values) (int, string) {
+		if action == "BindEIP" && p.Get("EIPId") == "eip-pool01" {
+			if binds++; binds == 1 {
+				return 8042, "bind refused"
+			}
+		}
+		return 0, ""
+	}
+
+	report, err := rotateOnce(context.Background(), task)
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Rotated != 1 {
+		t.Fatalf("report = %+v, want 1 rotated", report)
+	}
+	if got := f.callsOf("ReleaseEIP eip-pool01"); len(got) != 0 {
+		t.Errorf("the pool EIP was released before the retry: %v", got)
+	}
+	// the retry takes the returned pool EIP again
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-pool01"}) {
+		t.Errorf("web-01 bound to %v, want [eip-pool01]", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+	"github.com/user/eip-rotator/eiprotator"
+)
+
+// hostBinding is one EIP bound to a resource, as found by DescribeEIP. Most of the tool speaks
+// of hosts, but UHostID/UHostName are the id and name of whatever resource ResourceType says.
+type hostBinding struct {
+	ProjectID     string
+	ResourceType  string // as DescribeEIP reports it, passed unchanged to UnBindEIP/BindEIP
+	UHostID       string
+	UHostName     string
+	EIPID         string
+	EIPBandwidth  int
+	EIPPayMode    string
+	EIPOperator   string
+	EIPChargeType string
+	EIPIPs        []string // every address of the EIP; dual-line EIPs have more than one
+	EIPCreated    time.Time
+	EIPTag        string // business group ("业务组"), carried over to the new EIP
+	EIPRemark     string
+	EIPStatus     string // one of the task's rotatable statuses, normally "used"
+	// NICID is set when the EIP sits on a secondary network interface (uni-*) of the host;
+	// PrivateIP is the interface address it maps to. Both are empty for the plain single-NIC case.
+	NICID     string
+	PrivateIP string
+	Region    string
+}
+
+// bindTarget is the resource the EIP has to be unbound from and the new one bound to
+func (b hostBinding) bindTarget() (resourceType, resourceID string) {
+	if b.NICID != "" {
+		return "uni", b.NICID
+	}
+	if b.ResourceType == "" {
+		return "uhost", b.UHostID
+	}
+	return b.ResourceType, b.UHostID
+}
+
+// discoverBindings lists the task's bound EIPs plus unbound EIPs carrying our markers, DiscoverConcurrency
+// projects at a time; bound counts every bound EIP per project, before the task's filters
+func discoverBindings(client *unet.UNetClient, task taskConfig, region string) (bindings []hostBinding, orphans []orphanEIP, bound map[string]int, err error) {
+	filter, err := discoveryFilter(task)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	workers := task.DiscoverConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	results := make([]projectDiscovery, len(task.Projects))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, project := range task.Projects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *projectDiscovery, project string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.err = discoverProject(client, task, region, project, filter, r)
+		}(&results[i], project)
+	}
+	wg.Wait()
+
+	// merged in project order, so the result does not depend on scheduling
+	var (
+		errs         []error
+		inaccessible []error
+		cidrSkipped  int
+	)
+	bound = map[string]int{}
+	for i, r := range results {
+		bindings = append(bindings, r.bindings...)
+		orphans = append(orphans, r.orphans...)
+		bound[task.Projects[i]] = r.bound
+		cidrSkipped += r.cidrSkipped
+		switch {
+		case r.err == nil:
+		case isProjectAccessError(r.err):
+			stdLogger().Warnf("skip project in region=%s: deleted or no longer accessible: %v", region, r.err)
+			inaccessible = append(inaccessible, r.err)
+		default:
+			errs = append(errs, r.err)
+		}
+	}
+	metrics.SetGauge("eip_rotator_inaccessible_projects", "Configured projects the task's credential could not access in the last discovery", float64(len(inaccessible)), "task", taskID(task), "region", region)
+	if len(inaccessible) == len(task.Projects) {
+		// nothing left to rotate is a configuration problem, not an empty region
+		errs = append(errs, inaccessible...)
+	}
+	if len(task.OnlyIPsInCIDR) > 0 {
+		stdLogger().Infof("only_ips_in_cidr region=%s: selected %d EIPs, skipped %d outside %v", region, len(bindings), cidrSkipped, task.OnlyIPsInCIDR)
+	}
+	return bindings, orphans, bound, errors.Join(errs...)
+}
+
+// projectDiscovery is what discoverProject found in one project
+type projectDiscovery struct {
+	bindings    []hostBinding
+	orphans     []orphanEIP
+	bound       int // every bound EIP in the project, before any filter
+	cidrSkipped int
+	err         error
+}
+
+// discoveryFilter is the task's EIP selection, applied by the eiprotator library so the binary and
+// its library users select the same EIPs
+func discoveryFilter(task taskConfig) (*eiprotator.Filter, error) {
+	return eiprotator.Config{
+		ResourceTypes:       task.ResourceTypes,
+		Statuses:            task.RotatableStatuses,
+		HostID:              task.HostID,
+		IncludeNamePatterns: task.IncludeNamePatterns,
+		ExcludeNamePatterns: task.ExcludeNamePatterns,
+		MinAge:              time.Duration(task.MinAgeHours) * time.Hour,
+		OnlyIPsInCIDR:       task.OnlyIPsInCIDR,
+	}.Filter()
+}
+
+func discoverProject(client *unet.UNetClient, task taskConfig, region, project string, filter *eiprotator.Filter, r *projectDiscovery) error {
+	lg := stdLogger()
+	callStart := time.Now()
+	deResp, err := eiprotator.DescribeEIPs(client, project)
+	if err != nil {
+		return fmt.Errorf("DescribeEIP project=%s: %w", project, err)
+	}
+	lg.Debugf("DescribeEIP region=%s project=%s returned=%d total=%d took=%s", region, project, len(deResp.EIPSet), deResp.TotalCount, time.Since(callStart))
+	// UNet has no quota API, so expose usage and let alerting compare it with the known quota
+	metrics.SetGauge("eip_rotator_project_eips", "EIPs currently held in the project (bound and unbound)", float64(deResp.TotalCount), "region", region, "project", project)
+	metrics.SetGauge("eip_rotator_project_unbound_eips", "Unbound EIPs currently held in the project", float64(deResp.UnbindCount), "region", region, "project", project)
+	now := time.Now()
+	for _, e := range deResp.EIPSet {
+		if strings.ToLower(e.Status) == "free" {
+			if tid, host, ok := parseMarker(e.Remark); ok && (task.HostID == "" || host == task.HostID) {
+				r.orphans = append(r.orphans, orphanEIP{ProjectID: project, EIPID: e.EIPId, IPs: eipIPs(e.EIPAddr), TaskID: tid, HostID: host, Created: time.Unix(int64(e.CreateTime), 0), Region: region})
+			} else if tid, ok := strings.CutPrefix(e.Remark, poolMarkerPrefix); ok && tid != "" {
+				o := freeEIP(e, project, region)
+				o.TaskID, o.Pool = tid, true
+				r.orphans = append(r.orphans, o)
+			} else if task.PreferExistingEIP && !strings.HasPrefix(e.Remark, "eip-rotator") {
+				o := freeEIP(e, project, region)
+				o.Stash = true
+				r.orphans = append(r.orphans, o)
+			}
+		}
+		if strings.ToLower(e.Status) == "used" {
+			r.bound++
+		}
+		switch reason, why := filter.Skip(e, now); {
+		case reason == eiprotator.Selected:
+			r.bindings = append(r.bindings, hostBindingOf(eiprotator.BindingOf(e, region, project)))
+		case reason == eiprotator.SkipStatus && e.Resource.ResourceID != "":
+			// attached but frozen (e.g. in arrears) or mid-operation: a swap would fail half way
+			lg.Infof("skip eip=%s region=%s project=%s resource=%s: status=%s is not rotatable (rotatable_statuses=%v)", e.EIPId, region, project, e.Resource.ResourceID, e.Status, filter.Statuses())
+		case reason == eiprotator.SkipHost:
+		case reason == eiprotator.SkipCIDR:
+			r.cidrSkipped++
+			fallthrough
+		default:
+			lg.Debugf("skip eip=%s region=%s project=%s host=%s: %s", e.EIPId, region, project, safeName(e.Resource.ResourceID), why)
+		}
+	}
+	return nil
+}
+
+// hostBindingOf is the rotation's view of a binding the library discovered
+func hostBindingOf(b eiprotator.Binding) hostBinding {
+	return hostBinding{
+		ProjectID:     b.ProjectID,
+		ResourceType:  b.ResourceType,
+		UHostID:       b.ResourceID,
+		UHostName:     b.ResourceName,
+		EIPID:         b.EIPID,
+		EIPBandwidth:  b.Bandwidth,
+		EIPPayMode:    b.PayMode,
+		EIPOperator:   b.Operator,
+		EIPChargeType: b.ChargeType,
+		EIPIPs:        b.IPs,
+		EIPCreated:    b.Created,
+		EIPTag:        b.Tag,
+		EIPRemark:     b.Remark,
+		EIPStatus:     b.Status,
+		NICID:         b.NICID,
+		PrivateIP:     b.PrivateIP,
+		Region:        b.Region,
+	}
+}
+
+// freeEIP describes an unbound EIP with its spec, for the standby pool and the existing-EIP stash
+func freeEIP(e unet.UnetEIPSet, project, region string) orphanEIP {
+	b := eiprotator.BindingOf(e, region, project)
+	return orphanEIP{ProjectID: project, EIPID: b.EIPID, IPs: b.IPs, Created: b.Created, Region: region,
+		Operator: b.Operator, Bandwidth: b.Bandwidth, PayMode: b.PayMode, ChargeType: b.ChargeType, Tag: b.Tag, Remark: b.Remark}
+}
+
+func eipIPs(addrs []unet.UnetEIPAddrSet) []string {
+	ips := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return ips
+}
+
+// staleBinding re-reads the old EIP and returns a non-empty reason when it is no longer bound to b's host
+func staleBinding(client *unet.UNetClient, b hostBinding) (string, error) {
+	req := client.NewDescribeEIPRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.EIPIds = []string{b.EIPID}
+	resp, err := client.DescribeEIP(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.EIPSet) == 0 {
+		return "old EIP no longer exists (discovery was stale)", nil
+	}
+	e := resp.EIPSet[0]
+	want := b.EIPStatus
+	if want == "" {
+		want = "used"
+	}
+	if !strings.EqualFold(e.Status, want) || e.Resource.ResourceID != b.UHostID {
+		return fmt.Sprintf("old EIP now status=%s resource=%s (discovery was stale)", e.Status, safeName(e.Resource.ResourceID)), nil
+	}
+	return "", nil
+}
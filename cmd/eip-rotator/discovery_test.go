@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRotateOnceSkipsFrozenEIP(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-ok", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	frozen := boundEIP("eip-frozen", "106.75.1.2", "uhost", "uhost-web02", "web-02")
+	frozen.Status = "freeze"
+	f.add("cn-bj2", "org-test", frozen)
+
+	report, err := rotateOnce(context.Background(), testTask("org-test"))
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Discovered != 1 || report.Rotated != 1 {
+		t.Fatalf("report = %+v, want only the used EIP discovered and rotated", report)
+	}
+	for _, action := range []string{"UnBindEIP eip-frozen", "ReleaseEIP eip-frozen"} {
+		if got := f.callsOf(action); len(got) != 0 {
+			t.Errorf("the frozen EIP was touched: %v", got)
+		}
+	}
+	if e, _ := f.eip("eip-frozen"); e.Status != "freeze" || e.Resource.ResourceID != "uhost-web02" {
+		t.Errorf("eip-frozen = %s on %q, want it left frozen on web-02", e.Status, e.Resource.ResourceID)
+	}
+
+	// rotatable_statuses replaces the default set
+	task := testTask("org-test")
+	task.RotatableStatuses = []string{"Freeze"}
+	bindings, _, _, err := discoverBindings(newUNetClient(context.Background(), newCredential(task), "cn-bj2"), task, "cn-bj2")
+	if err != nil {
+		t.Fatalf("discoverBindings: %v", err)
+	}
+	if len(bindings) != 1 || bindings[0].EIPID != "eip-frozen" {
+		t.Errorf("rotatable_statuses=[Freeze] selected %+v, want only eip-frozen", bindings)
+	}
+}
+
+// discoveredEIPs returns the EIP ids discoverBindings selects for task in cn-bj2
+func discoveredEIPs(t *testing.T, task taskConfig) []string {
+	t.Helper()
+	bindings, _, _, err := discoverBindings(newUNetClient(context.Background(), newCredential(task), "cn-bj2"), task, "cn-bj2")
+	if err != nil {
+		t.Fatalf("discoverBindings: %v", err)
+	}
+	var ids []string
+	for _, b := range bindings {
+		ids = append(ids, b.EIPID)
+	}
+	return ids
+}
+
+func TestDiscoverOnlyIPsInCIDR(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-a", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-b", "106.75.2.1", "uhost", "uhost-web02", "web-02"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-c", "117.50.1.1", "uhost", "uhost-web03", "web-03"))
+	tests := []struct {
+		cidrs []string
+		want  []string
+	}{
+		{nil, []string{"eip-a", "eip-b", "eip-c"}},
+		{[]string{"106.75.1.0/24"}, []string{"eip-a"}},
+		{[]string{"106.75.0.0/16", "117.50.1.1"}, []string{"eip-a", "eip-b", "eip-c"}},
+		{[]string{"10.0.0.0/8"}, nil},
+	}
+	for _, tt := range tests {
+		task := testTask("org-test")
+		task.OnlyIPsInCIDR = tt.cidrs
+		if got := discoveredEIPs(t, task); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("only_ips_in_cidr=%v selected %v, want %v", tt.cidrs, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverNamePatterns(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-web1", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-web2", "106.75.1.2", "uhost", "uhost-web02", "web-02-canary"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-db1", "106.75.1.3", "uhost", "uhost-db01", "db-01"))
+	tests := []struct {
+		include, exclude []string
+		want             []string
+	}{
+		{want: []string{"eip-web1", "eip-web2", "eip-db1"}},
+		{include: []string{"web-*"}, want: []string{"eip-web1", "eip-web2"}},
+		{exclude: []string{"*-canary"}, want: []string{"eip-web1", "eip-db1"}},
+		{include: []string{"web-*"}, exclude: []string{"*-canary"}, want: []string{"eip-web1"}},
+		{include: []string{"db-0[1-3]", "cache-*"}, want: []string{"eip-db1"}},
+	}
+	for _, tt := range tests {
+		task := testTask("org-test")
+		task.IncludeNamePatterns, task.ExcludeNamePatterns = tt.include, tt.exclude
+		if got := discoveredEIPs(t, task); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("include=%v exclude=%v selected %v, want %v", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
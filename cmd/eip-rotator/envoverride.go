@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envOverridePrefix starts the environment variables that override fields of a named task:
+// EIP_ROTATOR_<NAME>_<FIELD>, with NAME upper-cased and every character other than A-Z and 0-9
+// turned into "_" (task "prod-bj" -> EIP_ROTATOR_PROD_BJ_INTERVAL)
+const envOverridePrefix = "EIP_ROTATOR_"
+
+// envOverrideName is the NAME part of a task's override variables
+func envOverrideName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, name)
+}
+
+// applyEnvOverrides overrides fields of a named task from the environment, which wins over the
+// file. Supported fields:
+//
+//	_INTERVAL      seconds ("300") or a duration ("5m"); replaces interval and interval_sec
+//	_RUN_ON_START  true/false
+//	_ROTATE_BATCH  non-negative integer
+//
+// Tasks without a name have no stable identity to key variables on and are left alone.
+func applyEnvOverrides(t *taskConfig) error {
+	if t.Name == "" {
+		return nil
+	}
+	prefix := envOverridePrefix + envOverrideName(t.Name) + "_"
+	if v, ok := os.LookupEnv(prefix + "INTERVAL"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			t.Interval, t.IntervalDuration = n, ""
+		} else if _, err := parseInterval(v); err == nil {
+			t.IntervalDuration = v
+		} else {
+			return fmt.Errorf("task %s: %sINTERVAL=%q: want seconds or a duration like 30m", t.Name, prefix, v)
+		}
+		stdLogger().Infof("task %s: interval overridden by %sINTERVAL=%s", t.Name, prefix, v)
+	}
+	if v, ok := os.LookupEnv(prefix + "RUN_ON_START"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("task %s: %sRUN_ON_START=%q: want true or false", t.Name, prefix, v)
+		}
+		t.RunOnStart = &b
+		stdLogger().Infof("task %s: run_on_start overridden by %sRUN_ON_START=%s", t.Name, prefix, v)
+	}
+	if v, ok := os.LookupEnv(prefix + "ROTATE_BATCH"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("task %s: %sROTATE_BATCH=%q: want a non-negative integer", t.Name, prefix, v)
+		}
+		t.RotateBatch = n
+		stdLogger().Infof("task %s: rotate_batch overridden by %sROTATE_BATCH=%s", t.Name, prefix, v)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestApplyEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(taskConfig) bool
+		wantErr bool
+	}{
+		{
+			name: "seconds replace a duration interval",
+			env:  map[string]string{"EIP_ROTATOR_WEB_EU_1_INTERVAL": "900"},
+			want: func(t taskConfig) bool { return t.Interval == 900 && t.IntervalDuration == "" },
+		},
+		{
+			name: "a duration interval",
+			env:  map[string]string{"EIP_ROTATOR_WEB_EU_1_INTERVAL": "45m"},
+			want: func(t taskConfig) bool { return t.IntervalDuration == "45m" },
+		},
+		{
+			name: "run on start and batch",
+			env:  map[string]string{"EIP_ROTATOR_WEB_EU_1_RUN_ON_START": "false", "EIP_ROTATOR_WEB_EU_1_ROTATE_BATCH": "2"},
+			want: func(t taskConfig) bool { return t.RunOnStart != nil && !*t.RunOnStart && t.RotateBatch == 2 },
+		},
+		{
+			name: "another task's variables are ignored",
+			env:  map[string]string{"EIP_ROTATOR_DB_INTERVAL": "900"},
+			want: func(t taskConfig) bool { return t.Interval == 0 && t.IntervalDuration == "30m" },
+		},
+		{name: "bad interval", env: map[string]string{"EIP_ROTATOR_WEB_EU_1_INTERVAL": "soon"}, wantErr: true},
+		{name: "bad run on start", env: map[string]string{"EIP_ROTATOR_WEB_EU_1_RUN_ON_START": "maybe"}, wantErr: true},
+		{name: "negative batch", env: map[string]string{"EIP_ROTATOR_WEB_EU_1_ROTATE_BATCH": "-1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			task := taskConfig{Name: "web-eu.1", IntervalDuration: "30m"}
+			err := applyEnvOverrides(&task)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("applied %+v, want an error", task)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnvOverrides: %v", err)
+			}
+			if !tt.want(task) {
+				t.Errorf("task = %+v", task)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverridesSkipsUnnamedTasks(t *testing.T) {
+	t.Setenv("EIP_ROTATOR__INTERVAL", "900")
+	task := taskConfig{Interval: 600}
+	if err := applyEnvOverrides(&task); err != nil || task.Interval != 600 {
+		t.Errorf("unnamed task = %+v, %v; want it untouched", task, err)
+	}
+}
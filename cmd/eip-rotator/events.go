@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// rotationEvent is published after every successful swap, for downstream automation such as
+// security group or WAF allowlist updates
+type rotationEvent struct {
+	Time      time.Time `json:"time"`
+	Task      string    `json:"task"`
+	Region    string    `json:"region"`
+	ProjectID string    `json:"project_id"`
+	HostID    string    `json:"host_id"`
+	HostName  string    `json:"host_name,omitempty"`
+	OldEIP    string    `json:"old_eip"`
+	OldIPs    []string  `json:"old_ips"`
+	NewEIP    string    `json:"new_eip"`
+	NewIPs    []string  `json:"new_ips"`
+}
+
+// EventPublisher delivers rotation events to a message broker
+type EventPublisher interface {
+	Publish(ctx context.Context, subject string, payload []byte) error
+}
+
+var (
+	// eventPublisher is built from --events-url; nil disables events
+	eventPublisher EventPublisher
+	eventSubject   string
+	eventRetries   int
+)
+
+// eventQueueSize bounds the events waiting for delivery; while the broker is down longer than the
+// queue lasts, further events are dropped and counted rather than holding up swaps
+const eventQueueSize = 256
+
+// eventFlushTimeout is how long flushEvents waits for queued events on the way out
+const eventFlushTimeout = 30 * time.Second
+
+var (
+	eventsMu     sync.Mutex
+	eventQueue   chan rotationEvent // nil until startEventPublisher, and again after flushEvents
+	eventsDone   chan struct{}
+	eventPublish = 5 * time.Second // timeout of each delivery attempt
+)
+
+// newEventPublisher picks the implementation by the URL scheme
+func newEventPublisher(raw string) (EventPublisher, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("--events-url: %w", err)
+	}
+	switch u.Scheme {
+	case "nats":
+		return newNATSPublisher(u)
+	}
+	return nil, fmt.Errorf("--events-url: unsupported scheme %q (want nats://)", u.Scheme)
+}
+
+// startEventPublisher makes p the publisher and starts the goroutine that delivers queued events
+func startEventPublisher(p EventPublisher) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventPublisher = p
+	queue, done := make(chan rotationEvent, eventQueueSize), make(chan struct{})
+	eventQueue, eventsDone = queue, done
+	go func() {
+		defer close(done)
+		for ev := range queue {
+			deliverEvent(ev)
+		}
+	}()
+}
+
+// publishRotation queues the event for a finished swap; it never waits for the broker
+func publishRotation(task taskConfig, b hostBinding, newEIP string, newIPs []string) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if eventQueue == nil {
+		return
+	}
+	ev := rotationEvent{Time: time.Now().UTC(), Task: taskID(task), Region: b.Region, ProjectID: b.ProjectID, HostID: b.UHostID, HostName: b.UHostName,
+		OldEIP: b.EIPID, OldIPs: b.EIPIPs, NewEIP: newEIP, NewIPs: newIPs}
+	select {
+	case eventQueue <- ev:
+	default:
+		metrics.AddCounter("eip_rotator_event_publish_failures_total", "Rotation events that could not be delivered", 1)
+		stdLogger().Errorf("rotation event NOT delivered for region=%s host=%s old=%s new=%s: %d events already waiting for the broker", b.Region, b.UHostID, b.EIPID, newEIP, eventQueueSize)
+	}
+}
+
+// flushEvents stops taking events and waits up to timeout for the queued ones to be delivered
+func flushEvents(timeout time.Duration) {
+	eventsMu.Lock()
+	queue, done := eventQueue, eventsDone
+	eventQueue = nil
+	eventsMu.Unlock()
+	if queue == nil {
+		return
+	}
+	pending := len(queue)
+	close(queue)
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		stdLogger().Errorf("rotation events: gave up waiting for the broker after %s, up to %d events NOT delivered", timeout, pending+1)
+	}
+}
+
+// deliverEvent publishes ev, retrying eventRetries times; a failure is only logged and counted,
+// since the swap already happened and must not be reported as failed
+func deliverEvent(ev rotationEvent) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		stdLogger().Errorf("rotation event for region=%s host=%s: %v", ev.Region, ev.HostID, err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublish)
+		err = eventPublisher.Publish(ctx, eventSubject, payload)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= eventRetries {
+			break
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+	metrics.AddCounter("eip_rotator_event_publish_failures_total", "Rotation events that could not be delivered", 1)
+	stdLogger().Errorf("rotation event NOT delivered for region=%s host=%s old=%s new=%s after %d attempts: %v", ev.Region, ev.HostID, ev.OldEIP, ev.NewEIP, eventRetries+1, err)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingPublisher records events and holds every Publish until release is closed
+type blockingPublisher struct {
+	release chan struct{}
+	mu      sync.Mutex
+	got     []rotationEvent
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var ev rotationEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.got = append(p.got, ev)
+	return nil
+}
+
+func TestPublishRotationDoesNotWaitForTheBroker(t *testing.T) {
+	prevPublish := eventPublish
+	eventPublish = time.Minute
+	t.Cleanup(func() { eventPublish = prevPublish })
+	p := &blockingPublisher{release: make(chan struct{})}
+	startEventPublisher(p)
+	task := testTask("org-test")
+
+	start := time.Now()
+	// one in delivery plus a full queue; the rest are dropped
+	for i := 0; i < eventQueueSize+5; i++ {
+		publishRotation(task, hostBinding{Region: "cn-bj2", UHostID: "uhost-web01", EIPID: "eip-old01"}, "eip-new1", []string{"117.50.0.1"})
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("publishing took %s while the broker hung, want it queued", took)
+	}
+	close(p.release)
+	flushEvents(10 * time.Second)
+	if n := len(p.got); n < eventQueueSize || n > eventQueueSize+1 {
+		t.Errorf("delivered %d events, want the %d queued (and the one in flight)", n, eventQueueSize)
+	}
+	if ev := p.got[0]; ev.HostID != "uhost-web01" || ev.OldEIP != "eip-old01" || ev.NewEIP != "eip-new1" {
+		t.Errorf("event = %+v", ev)
+	}
+	// after the flush nothing is queued any more
+	publishRotation(task, hostBinding{Region: "cn-bj2"}, "eip-new2", nil)
+}
+
+// fakeNATS accepts one connection, answers with replies after reading the PING that ends a
+// publish, and sends what the client wrote on the returned channel
+func fakeNATS(t *testing.T, info string, replies ...string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO " + info + "\r\n"))
+		var sent strings.Builder
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			sent.WriteString(line)
+			if line == "PING\r\n" {
+				for _, reply := range replies {
+					conn.Write([]byte(reply + "\r\n"))
+				}
+				r.ReadString('\n') // the client's PONG, if any
+				break
+			}
+		}
+		got <- sent.String()
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestNATSPublisher(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		info    string
+		replies []string
+		wantErr string
+		wantIn  []string // substrings of what the client sent
+	}{
+		{name: "acknowledged", url: "nats://%s", info: `{"server_id":"x"}`, replies: []string{"PONG"},
+			wantIn: []string{`"name":"eip-rotator"`, "PUB eip-rotator.rotations 18\r\n{\"new\":\"eip-new1\"}\r\n"}},
+		{name: "server PING before the PONG", url: "nats://%s", info: `{}`, replies: []string{"PING", "PONG"}},
+		{name: "user and password", url: "nats://alice:secret@%s", info: `{}`, replies: []string{"PONG"},
+			wantIn: []string{`"user":"alice"`, `"pass":"secret"`}},
+		{name: "token", url: "nats://s3cr3t@%s", info: `{}`, replies: []string{"PONG"}, wantIn: []string{`"auth_token":"s3cr3t"`}},
+		{name: "authorization error", url: "nats://%s", info: `{}`, replies: []string{"-ERR 'Authorization Violation'"}, wantErr: "Authorization Violation"},
+		{name: "TLS required", url: "nats://%s", info: `{"tls_required":true}`, wantErr: "requires TLS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, sent := fakeNATS(t, tt.info, tt.replies...)
+			u, err := url.Parse(strings.Replace(tt.url, "%s", addr, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := newNATSPublisher(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = p.Publish(ctx, "eip-rotator.rotations", []byte(`{"new":"eip-new1"}`))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			out := <-sent
+			for _, want := range tt.wantIn {
+				if !strings.Contains(out, want) {
+					t.Errorf("client sent %q, want it to contain %q", out, want)
+				}
+			}
+		})
+	}
+}
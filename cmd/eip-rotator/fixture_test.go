@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// fakeUCloud is an httptest server speaking the UCloud API for the actions the rotator calls. Its
+// EIP inventory is seeded from recorded DescribeEIP responses in testdata and then changed by the
+// calls the rotator makes, so a test can assert both the call sequence and the final state.
+type fakeUCloud struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	eips    map[string]*fakeEIP // by EIP id
+	order   []string            // EIP ids in the order DescribeEIP lists them
+	regions []string
+	calls   []string // "Action eip-id" (or just "Action") in call order
+	nextID  int
+	// allocEmpty makes that many AllocateEIP calls succeed with an empty EIPSet; listDelay leaves
+	// each such EIP out of that many DescribeEIP listings, as the API's eventual consistency may
+	allocEmpty int
+	listDelay  int
+	hidden     map[string]int // EIP id -> DescribeEIP listings it is still left out of
+	// firewalls is the UFirewall applied to each resource id, as DescribeFirewall reports it
+	firewalls map[string]string
+	// fail, when set, can fail a call: a non-zero RetCode is returned with the message instead of
+	// performing the action
+	fail func(action string, p url.Values) (retCode int, message string)
+}
+
+type fakeEIP struct {
+	region, project string
+	unet.UnetEIPSet
+}
+
+// newFakeUCloud starts the server and points every SDK client at it for the test's duration. The
+// state store and the project cache are replaced so tests do not see each other's state, and
+// retry backoffs are shortened.
+func newFakeUCloud(t *testing.T) *fakeUCloud {
+	t.Helper()
+	f := &fakeUCloud{t: t, eips: map[string]*fakeEIP{}, hidden: map[string]int{}, firewalls: map[string]string{}}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+
+	prevURL, prevState, prevProjects := apiBaseURL, state, projectCache
+	prevRelease, prevLookup := releaseBackoff, allocLookupBackoff
+	apiBaseURL = f.srv.URL
+	state = &stateStore{Tasks: map[string]*taskState{}}
+	projectCache = map[string]projectCacheEntry{}
+	releaseBackoff, allocLookupBackoff = time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		apiBaseURL, state, projectCache = prevURL, prevState, prevProjects
+		releaseBackoff, allocLookupBackoff = prevRelease, prevLookup
+	})
+	return f
+}
+
+// load adds the EIPs of a recorded DescribeEIP response to region and project
+func (f *fakeUCloud) load(region, project, file string) {
+	f.t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var resp unet.DescribeEIPResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		f.t.Fatalf("parse %s: %v", file, err)
+	}
+	for _, e := range resp.EIPSet {
+		f.add(region, project, e)
+	}
+}
+
+// add puts one EIP into the inventory
+func (f *fakeUCloud) add(region, project string, e unet.UnetEIPSet) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !containsString(f.regions, region) {
+		f.regions = append(f.regions, region)
+	}
+	f.eips[e.EIPId] = &fakeEIP{region: region, project: project, UnetEIPSet: e}
+	f.order = append(f.order, e.EIPId)
+}
+
+// boundEIP is a used EIP on resource id (uhost-..., udb-...) for add
+func boundEIP(eipID, ip, resourceType, resourceID, name string) unet.UnetEIPSet {
+	return unet.UnetEIPSet{
+		EIPId: eipID, Status: "used", PayMode: "Bandwidth", ChargeType: "Dynamic", Bandwidth: 5, Tag: "Default",
+		CreateTime: int(time.Now().Add(-48 * time.Hour).Unix()),
+		EIPAddr:    []unet.UnetEIPAddrSet{{IP: ip, OperatorName: "Bgp"}},
+		Resource:   unet.UnetEIPResourceSet{EIPId: eipID, ResourceID: resourceID, ResourceName: name, ResourceType: resourceType},
+	}
+}
+
+// eip returns a copy of an EIP's current state; ok is false once it was released
+func (f *fakeUCloud) eip(id string) (unet.UnetEIPSet, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.eips[id]
+	if !ok {
+		return unet.UnetEIPSet{}, false
+	}
+	return e.UnetEIPSet, true
+}
+
+// boundTo returns the ids of the EIPs bound to resource id, sorted
+func (f *fakeUCloud) boundTo(id string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for eipID, e := range f.eips {
+		if e.Status == "used" && e.Resource.ResourceID == id {
+			out = append(out, eipID)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// callsOf returns the recorded calls of one action, e.g. "BindEIP eip-new1"
+func (f *fakeUCloud) callsOf(action string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.calls {
+		if c == action || strings.HasPrefix(c, action+" ") {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// mutations returns every recorded call except the read-only ones
+func (f *fakeUCloud) mutations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.calls {
+		if strings.HasPrefix(c, "Describe") || strings.HasPrefix(c, "Get") {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (f *fakeUCloud) serve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := r.Form
+	action := p.Get("Action")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id := p.Get("EIPId"); id != "" {
+		f.calls = append(f.calls, action+" "+id)
+	} else {
+		f.calls = append(f.calls, action)
+	}
+	body := map[string]any{"Action": action + "Response", "RetCode": 0}
+	if f.fail != nil {
+		if code, msg := f.fail(action, p); code != 0 {
+			body["RetCode"], body["Message"] = code, msg
+			writeJSON(w, body)
+			return
+		}
+	}
+	if code, msg := f.handle(action, p, body); code != 0 {
+		body["RetCode"], body["Message"] = code, msg
+	}
+	writeJSON(w, body)
+}
+
+// handle performs one action on the inventory and fills in the response body
+func (f *fakeUCloud) handle(action string, p url.Values, body map[string]any) (int, string) {
+	region, project := p.Get("Region"), p.Get("ProjectId")
+	lookup := func() (*fakeEIP, int, string) {
+		e, ok := f.eips[p.Get("EIPId")]
+		if !ok || e.region != region || e.project != project {
+			return nil, 8039, fmt.Sprintf("EIP %s not found", p.Get("EIPId"))
+		}
+		return e, 0, ""
+	}
+	switch action {
+	case "GetRegion":
+		var regions []map[string]any
+		for _, r := range f.regions {
+			regions = append(regions, map[string]any{"Region": r, "Zone": r + "-01", "IsDefault": false})
+		}
+		body["Regions"] = regions
+	case "GetProjectList":
+		seen := map[string]bool{}
+		var projects []map[string]any
+		for _, id := range f.order {
+			if e, ok := f.eips[id]; ok && !seen[e.project] {
+				seen[e.project] = true
+				projects = append(projects, map[string]any{"ProjectId": e.project, "ProjectName": e.project})
+			}
+		}
+		body["ProjectSet"], body["ProjectCount"] = projects, len(projects)
+	case "DescribeEIP":
+		ids := listParam(p, "EIPIds")
+		var set []unet.UnetEIPSet
+		unbound := 0
+		for _, id := range f.order {
+			e, ok := f.eips[id]
+			if !ok || e.region != region || e.project != project {
+				continue
+			}
+			if len(ids) > 0 && !containsString(ids, id) {
+				continue
+			}
+			// counted down once per listing, not per page
+			if f.hidden[id] > 0 {
+				if p.Get("Offset") == "" || p.Get("Offset") == "0" {
+					f.hidden[id]--
+				}
+				continue
+			}
+			if e.Status == "free" {
+				unbound++
+			}
+			set = append(set, e.UnetEIPSet)
+		}
+		body["TotalCount"], body["UnbindCount"] = len(set), unbound
+		offset, _ := strconv.Atoi(p.Get("Offset"))
+		limit, err := strconv.Atoi(p.Get("Limit"))
+		if err != nil || limit <= 0 {
+			limit = 20 // the API's default page size
+		}
+		if offset > len(set) {
+			offset = len(set)
+		}
+		if offset+limit < len(set) {
+			set = set[offset : offset+limit]
+		} else {
+			set = set[offset:]
+		}
+		body["EIPSet"] = set
+	case "AllocateEIP":
+		f.nextID++
+		id := fmt.Sprintf("eip-new%d", f.nextID)
+		bw, _ := strconv.Atoi(p.Get("Bandwidth"))
+		e := &fakeEIP{region: region, project: project, UnetEIPSet: unet.UnetEIPSet{
+			EIPId: id, Status: "free", Remark: p.Get("Remark"), Tag: p.Get("Tag"), Bandwidth: bw,
+			PayMode: p.Get("PayMode"), ChargeType: p.Get("ChargeType"), CreateTime: int(time.Now().Unix()),
+			EIPAddr: []unet.UnetEIPAddrSet{{IP: fmt.Sprintf("117.50.0.%d", f.nextID), OperatorName: p.Get("OperatorName")}},
+		}}
+		f.eips[id] = e
+		f.order = append(f.order, id)
+		if f.allocEmpty > 0 {
+			f.allocEmpty--
+			f.hidden[id] = f.listDelay
+			body["EIPSet"] = []any{}
+			break
+		}
+		body["EIPSet"] = []unet.UnetAllocateEIPSet{{EIPId: id, EIPAddr: e.EIPAddr}}
+	case "BindEIP":
+		e, code, msg := lookup()
+		if code != 0 {
+			return code, msg
+		}
+		if e.Status != "free" {
+			return 8042, fmt.Sprintf("EIP %s is %s", e.EIPId, e.Status)
+		}
+		e.Status = "used"
+		e.Resource = unet.UnetEIPResourceSet{EIPId: e.EIPId, ResourceID: p.Get("ResourceId"), ResourceType: p.Get("ResourceType"), ResourceName: f.resourceName(p.Get("ResourceId"))}
+	case "UnBindEIP":
+		e, code, msg := lookup()
+		if code != 0 {
+			return code, msg
+		}
+		if e.Status != "used" || e.Resource.ResourceID != p.Get("ResourceId") {
+			return 8043, fmt.Sprintf("EIP %s is not bound to %s", e.EIPId, p.Get("ResourceId"))
+		}
+		e.Status = "free"
+		e.Resource = unet.UnetEIPResourceSet{}
+	case "ReleaseEIP":
+		e, code, msg := lookup()
+		if code != 0 {
+			return code, msg
+		}
+		if e.Status != "free" {
+			return 8044, fmt.Sprintf("EIP %s is %s and cannot be released", e.EIPId, e.Status)
+		}
+		delete(f.eips, e.EIPId)
+	case "UpdateEIPAttribute":
+		e, code, msg := lookup()
+		if code != 0 {
+			return code, msg
+		}
+		if v, ok := p["Remark"]; ok {
+			e.Remark = v[0]
+		}
+		if v, ok := p["Tag"]; ok {
+			e.Tag = v[0]
+		}
+	case "DescribeFirewall":
+		body["DataSet"] = []any{}
+		if fw, ok := f.firewalls[p.Get("ResourceId")]; ok {
+			body["DataSet"] = []any{map[string]any{"FWId": fw}}
+		}
+	case "GrantFirewall":
+		f.firewalls[p.Get("ResourceId")] = p.Get("FWId")
+	default:
+		return 160, fmt.Sprintf("action %s is not supported by the fixture", action)
+	}
+	return 0, ""
+}
+
+// resourceName keeps the name a resource had in the inventory, as the API reports it on every EIP
+func (f *fakeUCloud) resourceName(id string) string {
+	for _, e := range f.eips {
+		if e.Resource.ResourceID == id && e.Resource.ResourceName != "" {
+			return e.Resource.ResourceName
+		}
+	}
+	return ""
+}
+
+// listParam collects a list parameter the SDK encodes as Name.0, Name.1, ...
+func listParam(p url.Values, name string) []string {
+	var out []string
+	for i := 0; ; i++ {
+		v, ok := p[fmt.Sprintf("%s.%d", name, i)]
+		if !ok {
+			return out
+		}
+		out = append(out, v[0])
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// testTask is a single-region task against the fixture
+func testTask(projects ...string) taskConfig {
+	return taskConfig{Name: "test", PublicKey: "pub", PrivateKey: "priv", Projects: projects, Region: "cn-bj2"}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// forceSpec is a canonical spec every replacement EIP of the task is allocated with, whatever the
+// old EIP had; rotation then also converges EIPs that drifted from it
+type forceSpec struct {
+	Operator   string `json:"operator" toml:"operator"`
+	Bandwidth  int    `json:"bandwidth" toml:"bandwidth"`
+	PayMode    string `json:"pay_mode" toml:"pay_mode"`
+	ChargeType string `json:"charge_type" toml:"charge_type"`
+}
+
+// validateForceSpec requires every field of force_spec and a combination AllocateEIP accepts. The
+// per-field overrides are refused next to it, since force_spec would silently win over them.
+func validateForceSpec(t taskConfig) error {
+	f := t.ForceSpec
+	if f == nil {
+		return nil
+	}
+	if strings.TrimSpace(f.Operator) == "" || f.Bandwidth == 0 || f.PayMode == "" || f.ChargeType == "" {
+		return errors.New("force_spec needs operator, bandwidth, pay_mode and charge_type")
+	}
+	if err := validateBilling(f.PayMode, f.ChargeType); err != nil {
+		return fmt.Errorf("force_spec: %w", err)
+	}
+	if min, max := bandwidthRange(f.PayMode); f.Bandwidth < min || f.Bandwidth > max {
+		return fmt.Errorf("force_spec: bandwidth %dMbps is outside %d-%dMbps allowed for pay mode %s", f.Bandwidth, min, max, f.PayMode)
+	}
+	if t.NewPayMode != "" || t.NewChargeType != "" || len(t.OperatorMap) > 0 {
+		return errors.New("force_spec cannot be combined with new_pay_mode, new_charge_type or operator_map")
+	}
+	return nil
+}
+
+// forcedSpec is the allocSpec of b's replacement under force_spec, logging what it overrides
+func forcedSpec(task taskConfig, b hostBinding) allocSpec {
+	f := task.ForceSpec
+	s := allocSpec{Operator: f.Operator, Bandwidth: f.Bandwidth, PayMode: f.PayMode, ChargeType: f.ChargeType, Tag: tagFor(task, b)}
+	if !strings.EqualFold(s.Operator, b.EIPOperator) || s.Bandwidth != b.EIPBandwidth || s.PayMode != b.EIPPayMode || s.ChargeType != b.EIPChargeType {
+		stdLogger().Infof("force_spec region=%s host=%s(%s) eip=%s: operator %s -> %s, bandwidth %dMbps -> %dMbps, pay_mode %s -> %s, charge_type %s -> %s",
+			b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, b.EIPOperator, s.Operator, b.EIPBandwidth, s.Bandwidth, b.EIPPayMode, s.PayMode, b.EIPChargeType, s.ChargeType)
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// maxGlobalConcurrency is --max-global-concurrency: how many host rotations may be in their
+// allocate/bind/release sequence at once across every task and region of the process; 0 = no limit
+var maxGlobalConcurrency int
+
+// rotationSlots is the process-wide semaphore behind maxGlobalConcurrency, sized in main
+var rotationSlots chan struct{}
+
+// hostSlot holds at most one rotation slot for the host loop of rotateOnceForRegion: acquire takes
+// a slot for the current host and release (also called for the next host, and deferred) returns it
+type hostSlot struct {
+	held bool
+}
+
+func (s *hostSlot) acquire(ctx context.Context, b hostBinding) error {
+	if maxGlobalConcurrency <= 0 || s.held {
+		return nil
+	}
+	select {
+	case rotationSlots <- struct{}{}:
+	default:
+		start := time.Now()
+		stdLogger().Debugf("region=%s host=%s waiting for one of %d global rotation slots", b.Region, b.UHostID, maxGlobalConcurrency)
+		select {
+		case rotationSlots <- struct{}{}:
+			metrics.AddCounter("eip_rotator_global_slot_wait_seconds_total", "Seconds host rotations waited for a --max-global-concurrency slot", time.Since(start).Seconds())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.held = true
+	return nil
+}
+
+func (s *hostSlot) release() {
+	if s.held {
+		<-rotationSlots
+		s.held = false
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// taskHealth counts consecutive failed runs per scheduled task so a task that keeps failing is an
+// alertable condition (metrics and /readyz) rather than a repeating log line
+type taskHealthRegistry struct {
+	mu       sync.Mutex
+	failures map[string]int // task id -> consecutive failed runs
+}
+
+var taskHealth = &taskHealthRegistry{failures: map[string]int{}}
+
+// readyMaxFailures is --ready-max-failures: /readyz fails once any task has failed this many runs in a row
+var readyMaxFailures = 3
+
+// record notes the outcome of one run of t; finding nothing to rotate is not a failure
+func (h *taskHealthRegistry) record(t taskConfig, err error) {
+	id := taskID(t)
+	h.mu.Lock()
+	if err == nil {
+		h.failures[id] = 0
+	} else {
+		h.failures[id]++
+	}
+	n := h.failures[id]
+	h.mu.Unlock()
+	metrics.SetGauge("eip_rotator_task_consecutive_failures", "Runs of the task that failed in a row (0 after a successful run)", float64(n), "task", id)
+	if err == nil {
+		metrics.SetGauge("eip_rotator_task_last_success_timestamp_seconds", "Unix time of the task's last successful run", float64(time.Now().Unix()), "task", id)
+	}
+}
+
+// forget drops a task that the scheduler stopped
+func (h *taskHealthRegistry) forget(t taskConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failures, taskID(t))
+}
+
+// failing describes the tasks at or over readyMaxFailures, sorted
+func (h *taskHealthRegistry) failing() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var failing []string
+	for id, n := range h.failures {
+		if readyMaxFailures > 0 && n >= readyMaxFailures {
+			failing = append(failing, fmt.Sprintf("%s: %d failed runs in a row", id, n))
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// ServeHTTP is /readyz: 503 listing the tasks at or over readyMaxFailures, 200 otherwise
+func (h *taskHealthRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	failing := h.failing()
+	if len(failing) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, f := range failing {
+			fmt.Fprintln(w, f)
+		}
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// heartbeatURL is --heartbeat-url: an external dead man's switch (e.g. a Healthchecks.io check) that
+// is POSTed to while rotation is healthy, so a stalled or crashed scheduler shows up as a missed ping
+var heartbeatURL string
+
+var heartbeatClient = &http.Client{Timeout: 10 * time.Second}
+
+// sendHeartbeat POSTs body to heartbeatURL. A failed delivery is only logged: the receiving side
+// alerts on missing pings anyway, and rotation must not depend on it.
+func sendHeartbeat(body string) {
+	if heartbeatURL == "" {
+		return
+	}
+	err := func() error {
+		resp, err := heartbeatClient.Post(heartbeatURL, "text/plain", strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("status %s", resp.Status)
+		}
+		return nil
+	}()
+	if err != nil {
+		metrics.AddCounter("eip_rotator_heartbeat_failures_total", "Heartbeat pings that could not be delivered", 1)
+		stdLogger().Warnf("heartbeat to %s failed: %v", redactURL(heartbeatURL), err)
+		return
+	}
+	stdLogger().Debugf("heartbeat sent: %s", body)
+}
+
+// redactURL drops the path and query, where ping URLs usually carry their secret
+func redactURL(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		return "****"
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + host + "/****"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// heartbeatFailures reads eip_rotator_heartbeat_failures_total from the metrics page
+func heartbeatFailures() string {
+	for _, line := range strings.Split(scrape(), "\n") {
+		if v, ok := strings.CutPrefix(line, "eip_rotator_heartbeat_failures_total "); ok {
+			return v
+		}
+	}
+	return "0"
+}
+
+func TestSendHeartbeat(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		failed bool
+	}{
+		{name: "delivered", status: http.StatusOK},
+		{name: "any 2xx is delivered", status: http.StatusNoContent},
+		{name: "rejected ping is counted", status: http.StatusNotFound, failed: true},
+	}
+	defer func(u string) { heartbeatURL = u }(heartbeatURL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = append(got, r.Method+" "+r.URL.Path+" "+string(b))
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			heartbeatURL = srv.URL + "/ping/check-uuid"
+			before := heartbeatFailures()
+
+			sendHeartbeat("ok tasks=2")
+			if want := "POST /ping/check-uuid ok tasks=2"; len(got) != 1 || got[0] != want {
+				t.Errorf("server got %q, want %q", got, want)
+			}
+			if failed := heartbeatFailures() != before; failed != tt.failed {
+				t.Errorf("failure counted = %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestSendHeartbeatUnreachable(t *testing.T) {
+	defer func(u string) { heartbeatURL = u }(heartbeatURL)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	heartbeatURL = srv.URL
+	srv.Close()
+	before := heartbeatFailures()
+	sendHeartbeat("ok")
+	if heartbeatFailures() == before {
+		t.Error("an undeliverable heartbeat was not counted")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://hc-ping.com/0b8e2a1c-secret", "https://hc-ping.com/****"},
+		{"http://push.example.com:8080/api/push/abc?status=up", "http://push.example.com:8080/****"},
+		{"https://hc-ping.com", "https://hc-ping.com/****"},
+		{"not a url", "****"},
+	}
+	for _, tt := range tests {
+		if got := redactURL(tt.in); got != tt.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// hookContext is the data available to hook argument templates, e.g. {{.HostID}} or {{.NewIP}}
+type hookContext struct {
+	HostID    string
+	HostName  string
+	ProjectID string
+	Region    string
+	OldEIP    string
+	NewEIP    string
+	NewIP     string
+}
+
+// renderHookArgs expands each argument as a text/template against hc
+func renderHookArgs(args []string, hc hookContext) ([]string, error) {
+	out := make([]string, 0, len(args))
+	for _, a := range args {
+		tpl, err := template.New("hook").Option("missingkey=error").Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("parse hook arg %q: %w", a, err)
+		}
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, hc); err != nil {
+			return nil, fmt.Errorf("render hook arg %q: %w", a, err)
+		}
+		out = append(out, buf.String())
+	}
+	return out, nil
+}
+
+// runHook renders args and executes them through runContext; a non-zero exit is returned as an error
+func runHook(ctx context.Context, args []string, hc hookContext) error {
+	if len(args) == 0 {
+		return errors.New("empty hook command")
+	}
+	rendered, err := renderHookArgs(args, hc)
+	if err != nil {
+		return err
+	}
+	return runContext(ctx, rendered[0], rendered[1:]...)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderHookArgs(t *testing.T) {
+	hc := hookContext{HostID: "uhost-web01", HostName: "web-01", ProjectID: "org-test", Region: "cn-bj2", OldEIP: "eip-old01", NewEIP: "eip-new1", NewIP: "117.50.1.1"}
+	tests := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{args: []string{"notify", "--host={{.HostID}}"}, want: []string{"notify", "--host=uhost-web01"}},
+		{args: []string{"{{.OldEIP}}->{{.NewEIP}}", "{{.NewIP}}", "{{.Region}}/{{.ProjectID}}"}, want: []string{"eip-old01->eip-new1", "117.50.1.1", "cn-bj2/org-test"}},
+		{args: []string{"plain"}, want: []string{"plain"}},
+		{args: []string{"{{.Unknown}}"}, wantErr: true},
+		{args: []string{"{{.HostID"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := renderHookArgs(tt.args, hc)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q rendered to %q, want an error", tt.args, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q rendered to %q, %v; want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+// hookLog returns a hook command that appends its rendered arguments to a file, and the file
+func hookLog(t *testing.T, args ...string) ([]string, string) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "hook.log")
+	return append([]string{"sh", "-c", `echo "$@" >> "$0"`, out}, args...), out
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestPostHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      []string // nil records the rendered arguments
+		warnOnly bool
+		rotated  int
+	}{
+		{name: "gets the new EIP", rotated: 1},
+		{name: "failure fails the host", cmd: []string{"false"}, rotated: 0},
+		{name: "failure only warns when asked to", cmd: []string{"false"}, warnOnly: true, rotated: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+			task := testTask("org-test")
+			cmd, out := hookLog(t, "{{.HostID}}", "{{.OldEIP}}", "{{.NewEIP}}", "{{.NewIP}}")
+			if tt.cmd != nil {
+				cmd = tt.cmd
+			}
+			task.PostHookCmd, task.PostHookWarnOnly = cmd, tt.warnOnly
+
+			report, _ := rotateOnce(context.Background(), task)
+			if report.Rotated != tt.rotated || report.Failed != 1-tt.rotated {
+				t.Errorf("report = %+v, want %d rotated", report, tt.rotated)
+			}
+			// the swap is done before the hook runs, whatever it returns
+			if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new1"}) {
+				t.Errorf("uhost-web01 bound to %v, want [eip-new1]", got)
+			}
+			if tt.cmd == nil {
+				e, _ := f.eip("eip-new1")
+				want := []string{"uhost-web01 eip-old01 eip-new1 " + e.EIPAddr[0].IP}
+				if got := readLines(t, out); !reflect.DeepEqual(got, want) {
+					t.Errorf("hook ran with %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreHook(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     []string // nil records the rendered arguments and allows the swap
+		timeout int
+		reason  string // the skip reason wanted; "" wants the host rotated
+	}{
+		{name: "allows the swap"},
+		{name: "refusal skips the host", cmd: []string{"false"}, reason: "pre hook refused"},
+		{name: "timeout counts as a refusal", cmd: []string{"sleep", "5"}, timeout: 1, reason: "pre hook refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+			task := testTask("org-test")
+			cmd, out := hookLog(t, "{{.HostID}}", "{{.OldEIP}}", "new={{.NewEIP}}")
+			if tt.cmd != nil {
+				cmd = tt.cmd
+			}
+			task.PreHookCmd, task.PreHookTimeoutSec = cmd, tt.timeout
+
+			report, err := rotateOnce(context.Background(), task)
+			if err != nil {
+				t.Fatalf("rotateOnce: %v", err)
+			}
+			if tt.reason == "" {
+				if report.Rotated != 1 {
+					t.Errorf("report = %+v, want the host rotated", report)
+				}
+				// the new EIP is not known yet when the pre hook runs
+				if got, want := readLines(t, out), []string{"uhost-web01 eip-old01 new="}; !reflect.DeepEqual(got, want) {
+					t.Errorf("hook ran with %q, want %q", got, want)
+				}
+				return
+			}
+			if report.Rotated != 0 || len(report.Skipped) != 1 || !strings.HasPrefix(report.Skipped[0].Reason, tt.reason) {
+				t.Errorf("report = %+v, want the host skipped: %s", report, tt.reason)
+			}
+			// a refusal comes before anything is allocated or unbound
+			if got := f.mutations(); len(got) != 0 {
+				t.Errorf("mutations = %v, want none", got)
+			}
+		})
+	}
+}
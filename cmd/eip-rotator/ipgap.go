@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// ipGap is the window a swap left the host without a public IP: from sending UnBindEIP until the
+// new EIP was confirmed bound by DescribeEIP (or, when that check fails, until BindEIP returned).
+// make-before-break never leaves the host without one, so its gap is zero.
+type ipGap struct {
+	start, end time.Time
+	verified   bool
+}
+
+func (g ipGap) duration() time.Duration {
+	if g.start.IsZero() {
+		return 0
+	}
+	return g.end.Sub(g.start)
+}
+
+func (g ipGap) String() string {
+	s := g.duration().Round(time.Millisecond).String()
+	if !g.start.IsZero() && !g.verified {
+		s += "(unverified)"
+	}
+	return s
+}
+
+// hostGapMetric (--host-gap-metric) also exports each host's last gap; off by default since that
+// adds a series per rotated host
+var hostGapMetric bool
+
+// observe records the gap of a finished swap per task and region (histogram) and, with
+// hostGapMetric, per host (last value); the rotation log line carries each host's gap either way
+func (g ipGap) observe(task taskConfig, b hostBinding) {
+	secs := g.duration().Seconds()
+	metrics.ObserveHistogram("eip_rotator_public_ip_gap_seconds", "Seconds hosts were without a public IP during a rotation", secs, "task", taskID(task), "region", b.Region)
+	if hostGapMetric {
+		metrics.SetGauge("eip_rotator_host_public_ip_gap_seconds", "Seconds the host was without a public IP during its last rotation", secs, "task", taskID(task), "region", b.Region, "host", b.UHostID)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metrics registry in Prometheus text format
+func scrape() string {
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestIPGapObserve(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name     string
+		host     string
+		perHost  bool
+		gap      ipGap
+		wantHost string // the per-host series, or "" when none is expected
+	}{
+		{"histogram only by default", "uhost-gap01", false, ipGap{start: start, end: start.Add(1500 * time.Millisecond), verified: true}, ""},
+		{"per host with --host-gap-metric", "uhost-gap02", true, ipGap{start: start, end: start.Add(2 * time.Second), verified: true},
+			`eip_rotator_host_public_ip_gap_seconds{task="test",region="cn-gap",host="uhost-gap02"} 2`},
+		{"make-before-break has no gap", "uhost-gap03", true, ipGap{},
+			`eip_rotator_host_public_ip_gap_seconds{task="test",region="cn-gap",host="uhost-gap03"} 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := hostGapMetric
+			hostGapMetric = tt.perHost
+			defer func() { hostGapMetric = prev }()
+			tt.gap.observe(testTask("org-test"), hostBinding{Region: "cn-gap", UHostID: tt.host})
+
+			out := scrape()
+			if !strings.Contains(out, `eip_rotator_public_ip_gap_seconds_count{task="test",region="cn-gap"}`) {
+				t.Errorf("no gap histogram for the task and region in:\n%s", out)
+			}
+			if tt.wantHost == "" {
+				if strings.Contains(out, `host="`+tt.host+`"`) {
+					t.Errorf("per-host series for %s exported without --host-gap-metric", tt.host)
+				}
+			} else if !strings.Contains(out, tt.wantHost) {
+				t.Errorf("want %s in:\n%s", tt.wantHost, out)
+			}
+		})
+	}
+}
+
+func TestIPGapString(t *testing.T) {
+	start := time.Now()
+	for _, tt := range []struct {
+		gap  ipGap
+		want string
+	}{
+		{ipGap{}, "0s"},
+		{ipGap{start: start, end: start.Add(1234 * time.Millisecond), verified: true}, "1.234s"},
+		{ipGap{start: start, end: start.Add(time.Second)}, "1s(unverified)"},
+	} {
+		if got := tt.gap.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.gap, got, tt.want)
+		}
+	}
+}
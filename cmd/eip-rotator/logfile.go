@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// rotatingFile is the --log-file writer: once a write would grow the file past maxBytes it is
+// renamed to path.1 (older backups shift to path.2 ... path.<maxBackups>, the oldest is removed)
+// and a fresh file is started. A single line is never split across files.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// logFile is set from --log-file; nil keeps the loggers on stdout/stderr
+var logFile *rotatingFile
+
+func openLogFile(path string, maxSizeMB, maxBackups int) error {
+	if path == "" {
+		return nil
+	}
+	if maxSizeMB <= 0 {
+		return fmt.Errorf("--log-max-size-mb must be positive, got %d", maxSizeMB)
+	}
+	if maxBackups < 0 {
+		return fmt.Errorf("--log-max-backups must not be negative, got %d", maxBackups)
+	}
+	r := &rotatingFile{path: path, maxBytes: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return err
+	}
+	logFile = r
+	return nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			// keep logging to the current file rather than losing lines
+			fmt.Fprintf(os.Stderr, "eip-rotator log rotation failed: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(r.path, i), backupName(r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, backupName(r.path, 1)); err != nil {
+		// reopen so that later writes still have a file
+		if oerr := r.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	return r.open()
+}
+
+func backupName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// logWriter is where a logger writes: the --log-file when set, otherwise def
+func logWriter(def io.Writer) io.Writer {
+	if logFile != nil {
+		return logFile
+	}
+	return def
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelWarn
+	levelInfo
+	levelDebug
+)
+
+// currentLevel is set once from --log-level before any task starts
+var currentLevel = levelInfo
+
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error":
+		return levelError, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "info", "":
+		return levelInfo, nil
+	case "debug":
+		return levelDebug, nil
+	}
+	return levelInfo, fmt.Errorf("unknown log level %q (want error|warn|info|debug)", s)
+}
+
+// leveledLogger filters messages below currentLevel; info lines keep the plain format used so far.
+type leveledLogger struct {
+	*log.Logger
+}
+
+// stdLogger wraps the default logger configured in main
+func stdLogger() leveledLogger {
+	return leveledLogger{log.Default()}
+}
+
+func (l leveledLogger) logf(lv logLevel, prefix, format string, args ...interface{}) {
+	if lv > currentLevel {
+		return
+	}
+	l.Printf(prefix+format, args...)
+}
+
+func (l leveledLogger) Errorf(format string, args ...interface{}) {
+	l.logf(levelError, "error: ", format, args...)
+}
+
+func (l leveledLogger) Warnf(format string, args ...interface{}) {
+	l.logf(levelWarn, "warn: ", format, args...)
+}
+
+func (l leveledLogger) Infof(format string, args ...interface{}) {
+	l.logf(levelInfo, "", format, args...)
+}
+
+func (l leveledLogger) Debugf(format string, args ...interface{}) {
+	l.logf(levelDebug, "debug: ", format, args...)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// markerPrefix starts the Remark of every EIP this tool allocates. The full marker records which
+// task allocated it and for which host, so a swap interrupted between AllocateEIP and BindEIP can
+// be finished on the next run instead of leaking the EIP.
+const markerPrefix = "eip-rotator:"
+
+// poolMarkerPrefix starts the Remark of the task's standby pool EIPs (PoolSize); unlike
+// markerPrefix it names no host, since any host of the task may take them
+const poolMarkerPrefix = "eip-rotator-pool:"
+
+// boundMarkerPrefix replaces the allocation marker once the new EIP is bound and the swap is done.
+// The SDK cannot send an empty Remark, so the marker is overwritten rather than cleared; either
+// way a bound-for-good EIP must not look like an interrupted swap if it is ever unbound and kept.
+const boundMarkerPrefix = "eip-rotator-bound:"
+
+// keptMarkerPrefix tags an old EIP that is kept unbound after its swap (release disabled, deferred
+// or failed). It is neither an allocation nor a pool marker, so resume and cleanup pass it by, and
+// its "eip-rotator" start keeps it out of the PreferExistingEIP stash.
+const keptMarkerPrefix = "eip-rotator-kept:"
+
+// orphanEIP is an unbound EIP that still carries our allocation marker, or a standby pool EIP
+// (Pool set, HostID empty)
+type orphanEIP struct {
+	ProjectID string
+	EIPID     string
+	IPs       []string
+	TaskID    string
+	HostID    string
+	Created   time.Time
+	Region    string
+	Pool      bool
+	// Stash marks an unbound EIP without any marker of ours, found for PreferExistingEIP
+	Stash bool
+	// Remark is the EIP's remark when discovered, put back if a claimed stash EIP goes unused
+	Remark string
+	// spec of the EIP, so a pool or stash EIP is only used where it matches what would be allocated
+	Operator   string
+	Bandwidth  int
+	PayMode    string
+	ChargeType string
+	Tag        string
+}
+
+// taskID is the short task identity written into markers
+func taskID(t taskConfig) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return taskKey(t)[:12]
+}
+
+func allocationMarker(t taskConfig, hostID string) string {
+	return fmt.Sprintf("%s%s:%s", markerPrefix, taskID(t), hostID)
+}
+
+func boundMarker(t taskConfig) string {
+	return boundMarkerPrefix + taskID(t)
+}
+
+func keptMarker(t taskConfig) string {
+	return keptMarkerPrefix + taskID(t)
+}
+
+func poolMarker(t taskConfig) string {
+	return poolMarkerPrefix + taskID(t)
+}
+
+// parseMarker splits a marker back into task id and host id; the host id never contains ':'
+func parseMarker(remark string) (task, host string, ok bool) {
+	if !strings.HasPrefix(remark, markerPrefix) {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(remark, markerPrefix)
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// resumeSet holds this task's orphans by the host they were allocated for. A host can own several
+// EIPs, so it can also have several orphans; each rotation of one of its EIPs takes one.
+type resumeSet map[string][]orphanEIP
+
+func (r resumeSet) add(o orphanEIP) {
+	r[o.HostID] = append(r[o.HostID], o)
+}
+
+// newResumeSet collects the task's orphans by host, leaving out old EIPs on any pending release
+// list: resuming one would put its host back on the address it was rotated away from
+func newResumeSet(task taskConfig, orphans []orphanEIP) resumeSet {
+	pending := state.pendingIDs()
+	r := resumeSet{}
+	for _, o := range orphans {
+		if o.TaskID != taskID(task) || o.Pool || o.Stash || o.HostID == "" {
+			continue
+		}
+		if pending[o.EIPID] {
+			stdLogger().Infof("not resuming eip=%s region=%s project=%s: it is waiting to be released", o.EIPID, o.Region, o.ProjectID)
+			continue
+		}
+		r.add(o)
+	}
+	return r
+}
+
+// take removes and returns one orphan allocated for host
+func (r resumeSet) take(host string) (orphanEIP, bool) {
+	list := r[host]
+	if len(list) == 0 {
+		return orphanEIP{}, false
+	}
+	o := list[0]
+	if len(list) == 1 {
+		delete(r, host)
+	} else {
+		r[host] = list[1:]
+	}
+	return o, true
+}
+
+// clearRemark empties an EIP's remark. The SDK leaves empty fields out of every request, so this one
+// UpdateEIPAttribute is signed and sent by hand.
+func clearRemark(client *unet.UNetClient, projectID, eipID string) (err error) {
+	cfg := client.GetConfig()
+	start := time.Now()
+	defer func() { observeAPICall("UpdateEIPAttribute", cfg.Region, start, err) }()
+	payload := client.GetCredential().Apply(map[string]interface{}{
+		"Action": "UpdateEIPAttribute", "Region": cfg.Region, "ProjectId": projectID, "EIPId": eipID, "Remark": "",
+	})
+	form := url.Values{}
+	for k, v := range payload {
+		form.Set(k, fmt.Sprint(v))
+	}
+	req, err := http.NewRequest(http.MethodPost, cfg.BaseUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	httpClient := &http.Client{Transport: transportFor(context.Background()), Timeout: cfg.Timeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		RetCode int
+		Message string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("UpdateEIPAttribute: status %s: %w", resp.Status, err)
+	}
+	if body.RetCode != 0 {
+		return fmt.Errorf("UpdateEIPAttribute: RetCode=%d %s", body.RetCode, body.Message)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMakeBeforeBreakRollsBack(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fail func(action string, p url.Values) bool
+		want []string // mutations
+	}{
+		{
+			name: "bind",
+			fail: func(action string, p url.Values) bool { return action == "BindEIP" },
+			want: []string{"AllocateEIP", "BindEIP eip-new1", "ReleaseEIP eip-new1"},
+		},
+		{
+			name: "confirm",
+			fail: func(action string, p url.Values) bool {
+				return action == "DescribeEIP" && reflect.DeepEqual(listParam(p, "EIPIds"), []string{"eip-new1"})
+			},
+			want: []string{"AllocateEIP", "BindEIP eip-new1", "UnBindEIP eip-new1", "ReleaseEIP eip-new1"},
+		},
+		{
+			name: "unbind old",
+			fail: func(action string, p url.Values) bool { return action == "UnBindEIP" && p.Get("EIPId") == "eip-old01" },
+			want: []string{"AllocateEIP", "BindEIP eip-new1", "UnBindEIP eip-old01", "UnBindEIP eip-new1", "ReleaseEIP eip-new1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeUCloud(t)
+			task := testTask("org-test")
+			task.Strategy = strategyMakeBeforeBreak
+			f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+			f.fail = func(action string, p url.Values) (int, string) {
+				if tc.fail(action, p) {
+					return 8042, "refused"
+				}
+				return 0, ""
+			}
+
+			report, err := rotateOnce(context.Background(), task)
+			if err == nil || report.Failed != 1 {
+				t.Fatalf("report = %+v, err = %v; want the host failed", report, err)
+			}
+			if got := f.mutations(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("mutations:\n got %v\nwant %v", got, tc.want)
+			}
+			if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-old01"}) {
+				t.Errorf("web-01 bound to %v, want only the old [eip-old01]", got)
+			}
+			if _, ok := f.eip("eip-new1"); ok {
+				t.Error("the unused new EIP was not released")
+			}
+		})
+	}
+}
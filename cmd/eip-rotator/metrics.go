@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsRegistry is a tiny Prometheus text-format registry; enough for a handful of gauges,
+// counters and histograms
+type metricsRegistry struct {
+	mu     sync.Mutex
+	kinds  map[string]string                // name -> gauge|counter|histogram
+	help   map[string]string                // name -> help text
+	values map[string]map[string]float64    // name -> rendered labels -> value
+	hists  map[string]map[string]*histogram // name -> rendered labels -> histogram
+}
+
+var metrics = &metricsRegistry{
+	kinds:  map[string]string{},
+	help:   map[string]string{},
+	values: map[string]map[string]float64{},
+	hists:  map[string]map[string]*histogram{},
+}
+
+// apiLatencyBuckets are the histogram upper bounds, in seconds, for UCloud API calls
+var apiLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// histogram is one labelled series of a histogram: cumulative bucket counts plus sum and count
+type histogram struct {
+	labels []string
+	counts []uint64 // counts[i] observations <= apiLatencyBuckets[i]
+	sum    float64
+	count  uint64
+}
+
+// renderLabels turns key, value pairs into {k="v",...}
+func renderLabels(kv []string) string {
+	if len(kv) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, kv[i], v))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (r *metricsRegistry) series(kind, name, help string, labels []string) (map[string]float64, string) {
+	if _, ok := r.values[name]; !ok {
+		r.kinds[name] = kind
+		r.help[name] = help
+		r.values[name] = map[string]float64{}
+	}
+	return r.values[name], renderLabels(labels)
+}
+
+// SetGauge sets name{labels} to v; labels are key, value pairs
+func (r *metricsRegistry) SetGauge(name, help string, v float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, key := r.series("gauge", name, help, labels)
+	m[key] = v
+}
+
+// AddCounter increments name{labels} by delta
+func (r *metricsRegistry) AddCounter(name, help string, delta float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, key := r.series("counter", name, help, labels)
+	m[key] += delta
+}
+
+// ObserveHistogram records v in name{labels}, using apiLatencyBuckets as bucket bounds
+func (r *metricsRegistry) ObserveHistogram(name, help string, v float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, key := r.series("histogram", name, help, labels)
+	if _, ok := r.hists[name]; !ok {
+		r.hists[name] = map[string]*histogram{}
+	}
+	h, ok := r.hists[name][key]
+	if !ok {
+		h = &histogram{labels: append([]string(nil), labels...), counts: make([]uint64, len(apiLatencyBuckets))}
+		r.hists[name][key] = h
+		// the key is kept in values too so the series is listed in order with the others
+		m[key] = 0
+	}
+	for i, le := range apiLatencyBuckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// observeAPICall logs how long a UCloud API call took at debug level and records it in
+// eip_rotator_api_call_seconds, so a slow rotation can be traced to allocation, binding or release
+func observeAPICall(action, region string, start time.Time, err error) {
+	took := time.Since(start)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	stdLogger().Debugf("%s region=%s took=%s result=%s", action, region, took, result)
+	metrics.ObserveHistogram("eip_rotator_api_call_seconds", "Duration of UCloud API calls made while rotating, by action", took.Seconds(), "action", action, "region", region, "result", result)
+}
+
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	names := make([]string, 0, len(r.values))
+	for n := range r.values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", n, r.help[n], n, r.kinds[n])
+		keys := make([]string, 0, len(r.values[n]))
+		for k := range r.values[n] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if h, ok := r.hists[n][k]; ok {
+				for i, le := range apiLatencyBuckets {
+					fmt.Fprintf(w, "%s_bucket%s %d\n", n, renderLabels(append(h.labels[:len(h.labels):len(h.labels)], "le", fmt.Sprintf("%g", le))), h.counts[i])
+				}
+				fmt.Fprintf(w, "%s_bucket%s %d\n", n, renderLabels(append(h.labels[:len(h.labels):len(h.labels)], "le", "+Inf")), h.count)
+				fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", n, k, h.sum, n, k, h.count)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s %g\n", n, k, r.values[n][k])
+		}
+	}
+}
+
+// startMetricsServer serves /metrics and /readyz on addr in the background
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.Handle("/readyz", taskHealth)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			stdLogger().Errorf("metrics server on %s stopped: %v", addr, err)
+		}
+	}()
+	stdLogger().Infof("metrics listening on %s/metrics", addr)
+}
@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// operatorsForRegion lists the EIP lines AllocateEIP accepts in a region, preferred line first.
+// UNet has no API to list them, so this follows the SDK documentation: mainland regions sell Bgp
+// (Quanzhou only ChinaMobile), Hong Kong International or BGPPro, everything else International.
+func operatorsForRegion(region string) []string {
+	switch {
+	case region == "cn-qz":
+		return []string{"ChinaMobile"}
+	case strings.HasPrefix(region, "cn-"):
+		return []string{"Bgp"}
+	case region == "hk":
+		return []string{"International", "BGPPro"}
+	default:
+		return []string{"International"}
+	}
+}
+
+// operatorFor returns the line to allocate b's replacement on. The task's operator_map wins and is
+// trusted as is, since the operator knows lines the table above may not; otherwise the old EIP's
+// line when the region offers it, or else the region's default line with a warning.
+func operatorFor(task taskConfig, b hostBinding) string {
+	for from, to := range task.OperatorMap {
+		if strings.EqualFold(from, b.EIPOperator) {
+			stdLogger().Infof("region=%s host=%s(%s) eip=%s: operator %q remapped to %q by operator_map", b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, b.EIPOperator, to)
+			return to
+		}
+	}
+	valid := operatorsForRegion(b.Region)
+	for _, op := range valid {
+		if strings.EqualFold(op, b.EIPOperator) {
+			return b.EIPOperator
+		}
+	}
+	stdLogger().Warnf("region=%s host=%s(%s) eip=%s: operator %q is not offered in this region (valid: %s), allocating on %s instead; map it to itself in operator_map to keep it",
+		b.Region, safeName(b.UHostName), b.UHostID, b.EIPID, b.EIPOperator, strings.Join(valid, ","), valid[0])
+	return valid[0]
+}
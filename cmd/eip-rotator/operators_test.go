@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestOperatorFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		operator string
+		opMap    map[string]string
+		want     string
+	}{
+		{"offered line kept", "cn-bj2", "bgp", nil, "bgp"},
+		{"second offered line kept", "hk", "BGPPro", nil, "BGPPro"},
+		{"unoffered line falls back", "cn-bj2", "International", nil, "Bgp"},
+		{"line missing from the table falls back", "cn-sh2", "Telecom", nil, "Bgp"},
+		{"fallback outside the mainland", "us-ca", "Bgp", nil, "International"},
+		{"operator_map wins as is", "cn-bj2", "International", map[string]string{"international": "BGPPro"}, "BGPPro"},
+		{"operator_map keeps an unlisted line", "cn-sh2", "Telecom", map[string]string{"Telecom": "Telecom"}, "Telecom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := testTask("org-test")
+			task.OperatorMap = tt.opMap
+			b := hostBinding{Region: tt.region, UHostID: "uhost-web01", EIPID: "eip-old01", EIPOperator: tt.operator}
+			if got := operatorFor(task, b); got != tt.want {
+				t.Errorf("operatorFor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
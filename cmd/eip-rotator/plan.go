@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// runPlan prints, without changing anything, every action the next run would take, in its order
+func runPlan(w io.Writer, tasks []taskConfig) error {
+	ctx := context.Background()
+	var (
+		firstErr error
+		step     int
+		rotated  int
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		stdLogger().Errorf("plan: %v", err)
+	}
+	action := func(format string, args ...any) {
+		step++
+		fmt.Fprintf(w, "      %d. %s\n", step, fmt.Sprintf(format, args...))
+	}
+	for _, t := range tasks {
+		if err := checkRegionGuard(t); err != nil {
+			fail(fmt.Errorf("task %s: %w", taskLabel(t), err))
+			continue
+		}
+		ctx := withTask(ctx, t)
+		discoverCred := newDiscoverCredential(t)
+		regions, err := resolveRegions(ctx, t, discoverCred)
+		if err == nil {
+			t, err = expandProjects(ctx, t, discoverCred)
+		}
+		if err != nil {
+			fail(fmt.Errorf("task %s: %w", taskLabel(t), err))
+			continue
+		}
+		fmt.Fprintf(w, "task %s (strategy %s)\n", taskLabel(t), strategyOf(t))
+		for _, region := range regions {
+			bindings, orphans, eipsByProject, err := discoverBindings(newUNetClient(ctx, discoverCred, region), t, region)
+			if err != nil {
+				fail(fmt.Errorf("region=%s: %w", region, err))
+				if len(bindings) == 0 && len(orphans) == 0 {
+					continue
+				}
+			}
+			fmt.Fprintf(w, "  region %s\n", region)
+			if t.releaseOld() {
+				for _, p := range state.get(taskKey(t)).PendingRelease {
+					if p.Region == region {
+						action("release %s kept by an earlier run, if it is still unbound (project %s)", p.EIPID, p.ProjectID)
+					}
+				}
+			}
+			resume := newResumeSet(t, orphans)
+			pool := newStandbyPool(t, orphans)
+			stash := newStash(t, orphans)
+			batch, _, _ := peekBatch(t, region, bindings)
+			if err := checkRotateShare(t, region, batch, eipsByProject); err != nil && !force {
+				action("stop the region: %v", err)
+				continue
+			}
+			project := ""
+			bound := map[string]bool{}
+			for _, b := range bindings {
+				bound[b.UHostID] = true
+			}
+			for _, b := range batch {
+				if b.ProjectID != project {
+					project = b.ProjectID
+					fmt.Fprintf(w, "    project %s\n", project)
+				}
+				fmt.Fprintf(w, "     host %s(%s) eip %s(%s)\n", safeName(b.UHostName), b.UHostID, b.EIPID, strings.Join(b.EIPIPs, ","))
+				if reason := plannedSkip(t, b); reason != "" {
+					action("skip: %s", reason)
+					continue
+				}
+				if maxRotationsPerRun > 0 && rotated >= maxRotationsPerRun {
+					action("skip: max-rotations-per-run=%d reached", maxRotationsPerRun)
+					continue
+				}
+				rotated++
+				planHost(t, b, resume, pool, stash, action)
+			}
+			for host, left := range resume {
+				if bound[host] {
+					continue
+				}
+				o := left[0]
+				action("bind %s(%s), allocated by an interrupted run, to %s (project %s)", o.EIPID, strings.Join(o.IPs, ","), host, o.ProjectID)
+			}
+		}
+	}
+	if step == 0 {
+		fmt.Fprintln(w, "nothing to do")
+	}
+	return firstErr
+}
+
+func strategyOf(t taskConfig) string {
+	if t.makeBeforeBreak() {
+		return strategyMakeBeforeBreak
+	}
+	return strategyBreakBeforeMake
+}
+
+// plannedSkip returns why the next run would skip b without touching it, as far as that is known
+// before running: secondary NIC with make-before-break, cooldown, or the arm grace period
+func plannedSkip(t taskConfig, b hostBinding) string {
+	if t.makeBeforeBreak() && b.NICID != "" {
+		return fmt.Sprintf("strategy %s is not supported for EIPs on secondary NICs (fails the host)", strategyMakeBeforeBreak)
+	}
+	if t.MinRotationIntervalSec > 0 {
+		if last, ok := state.lastRotated(b.UHostID); ok {
+			if since := time.Since(last); since < time.Duration(t.MinRotationIntervalSec)*time.Second {
+				return fmt.Sprintf("in cooldown, rotated %s ago (min_rotation_interval_sec=%d)", since.Round(time.Second), t.MinRotationIntervalSec)
+			}
+		}
+	}
+	if t.ArmGracePeriodSec > 0 {
+		at, ok := armedAt(b.EIPRemark)
+		if !ok {
+			return fmt.Sprintf("arm as rotation candidate (append %s to the remark), rotates after arm_grace_period_sec=%d", armMarkerPrefix, t.ArmGracePeriodSec)
+		}
+		if since := time.Since(at); since < time.Duration(t.ArmGracePeriodSec)*time.Second {
+			return fmt.Sprintf("armed %s ago, rotates after arm_grace_period_sec=%d", since.Round(time.Second), t.ArmGracePeriodSec)
+		}
+	}
+	return ""
+}
+
+// planHost lists the steps rotateOnceForRegion takes for one host, in its order
+func planHost(t taskConfig, b hostBinding, resume resumeSet, pool, stash standbyPool, action func(string, ...any)) {
+	if len(t.PreHookCmd) > 0 {
+		action("run pre hook %v (a refusal skips the host)", t.PreHookCmd)
+	}
+	if t.PreserveFirewall {
+		action("record the firewall of %s", bindTargetLabel(b))
+	}
+	newEIP := "the new EIP"
+	if o, ok := resume.take(b.UHostID); ok {
+		newEIP = fmt.Sprintf("%s(%s)", o.EIPID, strings.Join(o.IPs, ","))
+		action("reuse %s allocated by an interrupted run", newEIP)
+	} else if o, ok := pool.find(t, b); ok {
+		newEIP = fmt.Sprintf("%s(%s)", o.EIPID, strings.Join(o.IPs, ","))
+		action("take standby %s from the pool and mark it for %s", newEIP, b.UHostID)
+	} else if o, ok := stash.find(t, b); ok {
+		newEIP = fmt.Sprintf("%s(%s)", o.EIPID, strings.Join(o.IPs, ","))
+		action("claim existing unbound %s (its remark is replaced by the marker for %s)", newEIP, b.UHostID)
+	} else {
+		spec, err := specFor(t, b)
+		if err != nil {
+			action("allocate a new EIP: would fail: %v", err)
+			return
+		}
+		desc := fmt.Sprintf("operator=%s bandwidth=%dMbps pay_mode=%s charge_type=%s", spec.Operator, spec.Bandwidth, spec.PayMode, spec.ChargeType)
+		if spec.Tag != "" {
+			desc += fmt.Sprintf(" tag=%q", spec.Tag)
+		}
+		if t.Zone != "" {
+			desc += " zone=" + t.Zone
+		}
+		action("allocate a new EIP in project %s: %s", b.ProjectID, desc)
+	}
+	action("re-check that %s is still bound to %s", b.EIPID, b.UHostID)
+	if t.makeBeforeBreak() {
+		action("bind %s to %s", newEIP, bindTargetLabel(b))
+		action("confirm %s is bound", newEIP)
+		action("unbind old %s from %s", b.EIPID, bindTargetLabel(b))
+	} else {
+		action("unbind old %s from %s", b.EIPID, bindTargetLabel(b))
+		action("bind %s to %s (on failure bind %s back)", newEIP, bindTargetLabel(b), b.EIPID)
+		action("confirm %s is bound (ends the public IP gap)", newEIP)
+	}
+	if t.PreserveFirewall {
+		action("re-apply the recorded firewall if it changed (the host fails if that fails)")
+	}
+	switch {
+	case !t.releaseOld():
+		action("keep old %s unbound and tag it %s (release disabled)", b.EIPID, keptMarker(t))
+	case t.DeferredRelease:
+		action("keep old %s until the next run and tag it %s (deferred release)", b.EIPID, keptMarker(t))
+	default:
+		action("release old %s", b.EIPID)
+	}
+	if len(t.PostHookCmd) > 0 {
+		action("run post hook %v", t.PostHookCmd)
+	}
+}
+
+func bindTargetLabel(b hostBinding) string {
+	typ, id := b.bindTarget()
+	if typ == "uni" {
+		return fmt.Sprintf("%s (nic %s)", b.UHostID, id)
+	}
+	return b.UHostID
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+// standbyPool is a task's unbound standby EIPs (PoolSize) in one region, by project. Members are
+// found again by their pool marker on every run, so a restart reuses them instead of leaking them.
+type standbyPool map[string][]orphanEIP
+
+// newStandbyPool collects the task's pool EIPs from discovery; it is empty when PoolSize is unset
+func newStandbyPool(task taskConfig, orphans []orphanEIP) standbyPool {
+	p := standbyPool{}
+	if task.PoolSize <= 0 {
+		return p
+	}
+	for _, o := range orphans {
+		if o.Pool && o.TaskID == taskID(task) {
+			p[o.ProjectID] = append(p[o.ProjectID], o)
+		}
+	}
+	return p
+}
+
+// newStash collects the unbound EIPs without our markers for PreferExistingEIP, except old EIPs
+// awaiting deferred release; with release_old off there is none, kept EIPs would look the same
+func newStash(task taskConfig, orphans []orphanEIP) standbyPool {
+	p := standbyPool{}
+	if !task.PreferExistingEIP || !task.releaseOld() {
+		return p
+	}
+	pending := map[string]bool{}
+	for _, pr := range state.get(taskKey(task)).PendingRelease {
+		pending[pr.EIPID] = true
+	}
+	for _, o := range orphans {
+		if o.Stash && !pending[o.EIPID] {
+			p[o.ProjectID] = append(p[o.ProjectID], o)
+		}
+	}
+	return p
+}
+
+func (o orphanEIP) matches(s allocSpec) bool {
+	return strings.EqualFold(o.Operator, s.Operator) && o.Bandwidth == s.Bandwidth && o.PayMode == s.PayMode && o.ChargeType == s.ChargeType && o.Tag == s.Tag
+}
+
+// find removes and returns a pool EIP in b's project with the spec b's replacement would be
+// allocated with
+func (p standbyPool) find(task taskConfig, b hostBinding) (orphanEIP, bool) {
+	list := p[b.ProjectID]
+	if len(list) == 0 {
+		return orphanEIP{}, false
+	}
+	spec, err := specFor(task, b)
+	if err != nil {
+		return orphanEIP{}, false
+	}
+	for i, o := range list {
+		if o.matches(spec) {
+			p[b.ProjectID] = append(list[:i:i], list[i+1:]...)
+			return o, true
+		}
+	}
+	return orphanEIP{}, false
+}
+
+// claim takes a matching pool (or stash) EIP for b's host, leasing it by writing and reading back
+// the host's allocation marker; ok is false when the caller has to allocate
+func (p standbyPool) claim(client *unet.UNetClient, task taskConfig, b hostBinding) (orphanEIP, bool) {
+	o, ok := p.find(task, b)
+	if !ok {
+		return orphanEIP{}, false
+	}
+	req := client.NewUpdateEIPAttributeRequest()
+	req.ProjectId = ucloud.String(o.ProjectID)
+	req.EIPId = ucloud.String(o.EIPID)
+	marker := allocationMarker(task, b.UHostID)
+	req.Remark = ucloud.String(marker)
+	if _, err := client.UpdateEIPAttribute(req); err != nil {
+		stdLogger().Warnf("region=%s host=%s(%s): could not claim unbound %s, allocating instead: %v", b.Region, safeName(b.UHostName), b.UHostID, o.EIPID, err)
+		return orphanEIP{}, false
+	}
+	check := client.NewDescribeEIPRequest()
+	check.ProjectId = ucloud.String(o.ProjectID)
+	check.EIPIds = []string{o.EIPID}
+	resp, err := client.DescribeEIP(check)
+	if err == nil && (len(resp.EIPSet) == 0 || !strings.EqualFold(resp.EIPSet[0].Status, "free") || resp.EIPSet[0].Remark != marker) {
+		err = errors.New("taken by someone else")
+	}
+	if err != nil {
+		stdLogger().Warnf("region=%s host=%s(%s): lost the claim on unbound %s, allocating instead: %v", b.Region, safeName(b.UHostName), b.UHostID, o.EIPID, err)
+		return orphanEIP{}, false
+	}
+	return o, true
+}
+
+// giveBack returns a claimed EIP that went unused: its remark is set back to remark (cleared when
+// empty) and the next host may take it again
+func (p standbyPool) giveBack(client *unet.UNetClient, o orphanEIP, remark string) error {
+	if remark == "" {
+		if err := clearRemark(client, o.ProjectID, o.EIPID); err != nil {
+			return err
+		}
+	} else {
+		req := client.NewUpdateEIPAttributeRequest()
+		req.ProjectId = ucloud.String(o.ProjectID)
+		req.EIPId = ucloud.String(o.EIPID)
+		req.Remark = ucloud.String(remark)
+		if _, err := client.UpdateEIPAttribute(req); err != nil {
+			return err
+		}
+	}
+	p[o.ProjectID] = append(p[o.ProjectID], o)
+	return nil
+}
+
+// eipSource is where a host's replacement EIP came from, which decides what happens to it unused
+type eipSource int
+
+const (
+	allocatedHere eipSource = iota // by AllocateEIP in this run
+	resumed                        // allocated for the host by an interrupted run
+	fromPool                       // the task's standby pool (PoolSize)
+	fromStash                      // an existing unbound EIP (PreferExistingEIP)
+)
+
+// discardUnused releases an unused replacement allocated in this run and hands any other back to
+// where it came from; on failure the allocation marker stays, so a later run resumes it
+func discardUnused(client *unet.UNetClient, task taskConfig, b hostBinding, o orphanEIP, src eipSource, resume resumeSet, pool, stash standbyPool) {
+	var err error
+	switch src {
+	case allocatedHere:
+		err = releaseEIP(client, b.ProjectID, o.EIPID)
+	case resumed:
+		// it still carries the host's marker
+		resume.add(o)
+	case fromPool:
+		err = pool.giveBack(client, o, poolMarker(task))
+	case fromStash:
+		err = stash.giveBack(client, o, o.Remark)
+	}
+	if err != nil {
+		stdLogger().Warnf("region=%s host=%s(%s) could not discard unused new %s, a later run reuses it: %v", b.Region, safeName(b.UHostName), b.UHostID, o.EIPID, err)
+	}
+}
+
+// replenish tops each project's pool back up to PoolSize after the swaps, so allocation stays out
+// of the critical window. New members get the spec of specSource[project], a binding of that
+// project; failures are only logged, since a short pool just means the next rotation allocates.
+func (p standbyPool) replenish(client *unet.UNetClient, task taskConfig, region string, specSource map[string]hostBinding) {
+	if task.PoolSize <= 0 {
+		return
+	}
+	lg := stdLogger()
+	for _, project := range task.Projects {
+		b, ok := specSource[project]
+		if !ok {
+			continue
+		}
+		spec, err := specFor(task, b)
+		if err != nil {
+			lg.Warnf("pool region=%s project=%s: not replenished: %v", region, project, err)
+			continue
+		}
+		for len(p[project]) < task.PoolSize {
+			id, ips, err := allocateWithSpec(client, task, b, spec, poolMarker(task))
+			if err != nil {
+				lg.Warnf("pool region=%s project=%s: not replenished: %v", region, project, err)
+				if errors.Is(err, errQuotaExceeded) {
+					return
+				}
+				break
+			}
+			p[project] = append(p[project], orphanEIP{ProjectID: project, EIPID: id, IPs: ips, TaskID: taskID(task), Region: region, Pool: true,
+				Operator: spec.Operator, Bandwidth: spec.Bandwidth, PayMode: spec.PayMode, ChargeType: spec.ChargeType, Tag: spec.Tag})
+			lg.Infof("pool region=%s project=%s: allocated standby %s(%s), %d/%d", region, project, id, strings.Join(ips, ","), len(p[project]), task.PoolSize)
+		}
+		metrics.SetGauge("eip_rotator_pool_eips", "Standby EIPs held in the task's pool", float64(len(p[project])), "task", taskID(task), "region", region, "project", project)
+	}
+}
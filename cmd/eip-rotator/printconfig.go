@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// runPrintConfig writes tasks as pretty JSON with defaults filled in and credentials redacted
+func runPrintConfig(w io.Writer, tasks []taskConfig) error {
+	out := make([]taskConfig, 0, len(tasks))
+	for _, t := range tasks {
+		t.PublicKey = redactKey(t.PublicKey, 4)
+		t.PrivateKey = redactKey(t.PrivateKey, 0)
+		if t.DiscoverPublicKey != "" {
+			t.DiscoverPublicKey = redactKey(t.DiscoverPublicKey, 4)
+			t.DiscoverPrivateKey = redactKey(t.DiscoverPrivateKey, 0)
+		}
+		if t.MaxAllocFailures <= 0 {
+			t.MaxAllocFailures = defaultMaxAllocFailures
+		}
+		if len(t.PreHookCmd) > 0 && t.PreHookTimeoutSec <= 0 {
+			t.PreHookTimeoutSec = defaultPreHookTimeoutSec
+		}
+		runOnStart := t.runOnStart()
+		t.RunOnStart = &runOnStart
+		out = append(out, t)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// redactKey keeps at most keep leading chars so public keys stay distinguishable without being usable
+func redactKey(s string, keep int) string {
+	if len(s) <= keep {
+		return "****"
+	}
+	return s[:keep] + "****"
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+)
+
+// allProjects in project_ids expands to every project the credential can see
+const allProjects = "all"
+
+// projectCacheTTL bounds how stale an auto-discovered project list may be
+const projectCacheTTL = 10 * time.Minute
+
+type projectCacheEntry struct {
+	projects []string
+	fetched  time.Time
+}
+
+var (
+	projectCacheMu sync.Mutex
+	projectCache   = map[string]projectCacheEntry{} // keyed by public key
+)
+
+func wantsAllProjects(projects []string) bool {
+	for _, p := range projects {
+		if strings.EqualFold(strings.TrimSpace(p), allProjects) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveProjects returns the task's explicit project ids, or the account's full list when set to "all"
+func resolveProjects(ctx context.Context, task taskConfig, credential *auth.Credential) ([]string, error) {
+	if !wantsAllProjects(task.Projects) {
+		return task.Projects, nil
+	}
+	projectCacheMu.Lock()
+	entry, ok := projectCache[credential.PublicKey]
+	projectCacheMu.Unlock()
+	if ok && time.Since(entry.fetched) < projectCacheTTL {
+		return entry.projects, nil
+	}
+
+	uacct := newUAccountClient(ctx, credential)
+	resp, err := uacct.GetProjectList(uacct.NewGetProjectListRequest())
+	if err != nil {
+		return nil, fmt.Errorf("list projects: %w", err)
+	}
+	projects := make([]string, 0, len(resp.ProjectSet))
+	for _, p := range resp.ProjectSet {
+		projects = append(projects, p.ProjectId)
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("list projects: no project visible to this credential")
+	}
+	stdLogger().Debugf("resolved project_ids=all to %d projects: %v", len(projects), projects)
+
+	projectCacheMu.Lock()
+	projectCache[credential.PublicKey] = projectCacheEntry{projects: projects, fetched: time.Now()}
+	projectCacheMu.Unlock()
+	return projects, nil
+}
+
+// expandProjects returns task with Projects resolved for discovery, keeping the configured list in
+// ProjectSpec for the task's identity
+func expandProjects(ctx context.Context, task taskConfig, credential *auth.Credential) (taskConfig, error) {
+	projects, err := resolveProjects(ctx, task, credential)
+	if err != nil {
+		return task, err
+	}
+	if task.ProjectSpec == nil {
+		task.ProjectSpec = task.Projects
+	}
+	task.Projects = projects
+	return task, nil
+}
+
+// splitList splits a comma-separated flag value, dropping blanks
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// readProjectsFile reads --projects-file: ids separated by newlines and/or commas, with blank lines
+// and # comments ignored. An existing file without a single id is an error.
+func readProjectsFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read --projects-file: %w", err)
+	}
+	var projects []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		projects = append(projects, splitList(line)...)
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("--projects-file %s has no project ids", path)
+	}
+	return projects, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTaskKeyIgnoresProjectExpansion(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-a", boundEIP("eip-a", "106.75.2.1", "uhost", "uhost-a", "a"))
+
+	task := testTask(allProjects)
+	task.Name = ""
+	key := taskKey(task)
+	expanded, err := expandProjects(context.Background(), task, newCredential(task))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expanded.Projects, []string{"org-a"}) {
+		t.Fatalf("expanded projects = %v", expanded.Projects)
+	}
+	if got := taskKey(expanded); got != key {
+		t.Errorf("key changed by expansion: %s -> %s", key, got)
+	}
+
+	// a project created later must not orphan the task's state either
+	projectCache = map[string]projectCacheEntry{}
+	f.add("cn-bj2", "org-b", boundEIP("eip-b", "106.75.2.2", "uhost", "uhost-b", "b"))
+	expanded, err = expandProjects(context.Background(), task, newCredential(task))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expanded.Projects) != 2 || taskKey(expanded) != key || taskID(expanded) != taskID(task) {
+		t.Errorf("after a new project: projects=%v key=%s id=%s, want key %s id %s", expanded.Projects, taskKey(expanded), taskID(expanded), key, taskID(task))
+	}
+}
+
+func TestTaskKeyFollowsName(t *testing.T) {
+	a := testTask("org-a")
+	b := a
+	b.Projects = []string{"org-a", "org-b"}
+	b.PublicKey = "rotated-pub"
+	if taskKey(a) != taskKey(b) {
+		t.Error("a named task's key changed with its projects and credentials")
+	}
+	a.Name, b.Name = "", ""
+	if taskKey(a) == taskKey(b) {
+		t.Error("unnamed tasks with different projects share a key")
+	}
+}
+
+func TestDeferredReleaseSurvivesProjectEdit(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+	task := testTask("org-test")
+	task.DeferredRelease = true
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.eip("eip-aaaa01"); !ok {
+		t.Fatal("old EIP released right away with deferred_release")
+	}
+
+	// a project is added to the task before its next run
+	f.add("cn-bj2", "org-new", boundEIP("eip-c", "106.75.3.1", "uhost", "uhost-c", "c"))
+	task.Projects = []string{"org-test", "org-new"}
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	for _, old := range []string{"eip-aaaa01", "eip-aaaa02"} {
+		if _, ok := f.eip(old); ok {
+			t.Errorf("deferred %s was not released after the project edit", old)
+		}
+	}
+}
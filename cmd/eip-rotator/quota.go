@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	uerr "github.com/ucloud/ucloud-sdk-go/ucloud/error"
+)
+
+// errQuotaExceeded matches (errors.Is) any AllocateEIP failure caused by the EIP quota; retrying
+// will not help until the quota is raised or EIPs are released
+var errQuotaExceeded = errors.New("EIP quota exceeded")
+
+// quotaError is an AllocateEIP failure classified as quota exhaustion
+type quotaError struct {
+	Region    string
+	ProjectID string
+	Err       error
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("EIP quota exceeded in region=%s project=%s: %v", e.Region, e.ProjectID, e.Err)
+}
+
+func (e *quotaError) Unwrap() error { return e.Err }
+
+func (e *quotaError) Is(target error) bool { return target == errQuotaExceeded }
+
+// isQuotaError recognizes a quota refusal from the API. UNet reports it as a server RetCode error
+// whose message names the quota; there is no documented dedicated RetCode, so match the message.
+func isQuotaError(err error) bool {
+	var ue uerr.Error
+	if !errors.As(err, &ue) || ue.Name() != uerr.ErrRetCode {
+		return false
+	}
+	msg := strings.ToLower(ue.Message())
+	return strings.Contains(msg, "quota") || strings.Contains(msg, "配额")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// region_order values
+const (
+	regionOrderListed   = "listed"
+	regionOrderRandom   = "random"
+	regionOrderWeighted = "weighted"
+)
+
+func validateRegionOrder(t taskConfig) error {
+	switch t.RegionOrder {
+	case "", regionOrderListed, regionOrderRandom, regionOrderWeighted:
+	default:
+		return invalidTask(t, "region_order must be %s, %s or %s", regionOrderListed, regionOrderRandom, regionOrderWeighted)
+	}
+	for r, w := range t.RegionWeights {
+		if w <= 0 {
+			return invalidTask(t, "region_weights[%s] must be positive", r)
+		}
+	}
+	if len(t.RegionWeights) > 0 && t.RegionOrder != regionOrderWeighted {
+		return invalidTask(t, "region_weights needs region_order = %q", regionOrderWeighted)
+	}
+	return nil
+}
+
+// orderRegions returns the order a run processes regions in; weighted draws without replacement
+// by region_weights (1 when unlisted), so a failing region does not always starve the same ones
+func orderRegions(t taskConfig, regions []string, rng *rand.Rand) []string {
+	out := append([]string(nil), regions...)
+	switch t.RegionOrder {
+	case regionOrderRandom:
+		rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	case regionOrderWeighted:
+		// Efraimidis-Spirakis: sorting by u^(1/w) descending is a weighted draw without replacement
+		keys := make(map[string]float64, len(out))
+		for _, r := range out {
+			w := t.RegionWeights[r]
+			if w <= 0 {
+				w = 1
+			}
+			keys[r] = math.Pow(rng.Float64(), 1/float64(w))
+		}
+		sort.SliceStable(out, func(i, j int) bool { return keys[out[i]] > keys[out[j]] })
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRotateOnceRotatesRegionsAfterEmptyOnes(t *testing.T) {
+	f := newFakeUCloud(t)
+	// cn-sh2 holds only an unbound EIP and hk nothing at all; both come before populated regions
+	f.add("cn-sh2", "org-test", unboundEIP("eip-sh01", "117.60.0.1", "spare"))
+	f.regions = append(f.regions, "hk")
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+	f.add("cn-gd", "org-test", boundEIP("eip-gd01", "106.76.0.1", "uhost", "uhost-gd01", "gd-01"))
+	task := testTask("org-test")
+	task.Region, task.Regions = "", []string{"cn-sh2", "hk", "cn-bj2", "cn-gd"}
+
+	report, err := rotateOnce(context.Background(), task)
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Discovered != 3 || report.Rotated != 3 || report.Failed != 0 {
+		t.Fatalf("report = %+v, want 3 discovered, 3 rotated", report)
+	}
+	for host, eip := range map[string]string{"uhost-web01": "eip-new1", "uhost-web02": "eip-new2", "uhost-gd01": "eip-new3"} {
+		if got := f.boundTo(host); !reflect.DeepEqual(got, []string{eip}) {
+			t.Errorf("%s bound to %v, want [%s]", host, got, eip)
+		}
+	}
+	if _, ok := f.eip("eip-sh01"); !ok {
+		t.Error("the unbound EIP in the empty region was released")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+func releaseEIP(client *unet.UNetClient, projectID, eipID string) error {
+	req := client.NewReleaseEIPRequest()
+	req.ProjectId = ucloud.String(projectID)
+	req.EIPId = ucloud.String(eipID)
+	start := time.Now()
+	_, err := client.ReleaseEIP(req)
+	observeAPICall("ReleaseEIP", client.GetConfig().Region, start, err)
+	return err
+}
+
+// releaseAttempts bounds releaseWithRetry; the backoff doubles from releaseBackoff
+const releaseAttempts = 3
+
+// releaseBackoff is a variable so tests against the API fixture need not wait for it
+var releaseBackoff = 2 * time.Second
+
+// releaseWithRetry retries ReleaseEIP briefly, since right after UnBindEIP the release can still be
+// refused until the unbind has propagated
+func releaseWithRetry(ctx context.Context, client *unet.UNetClient, projectID, eipID string) error {
+	backoff := releaseBackoff
+	var err error
+	for i := 0; i < releaseAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return err
+			}
+			backoff *= 2
+		}
+		if err = releaseEIP(client, projectID, eipID); err == nil {
+			return nil
+		}
+		stdLogger().Debugf("ReleaseEIP eip=%s attempt %d/%d failed: %v", eipID, i+1, releaseAttempts, err)
+	}
+	return err
+}
+
+// releaseDeferred releases old EIPs kept by DeferredRelease in an earlier run of this task. An EIP
+// is only released once it is still unbound, i.e. its replacement has held the host since then;
+// one that was bound again or is already gone is dropped from the list.
+func releaseDeferred(client *unet.UNetClient, task taskConfig, region string) {
+	lg := stdLogger()
+	key := taskKey(task)
+	var done []string
+	for _, p := range state.get(key).PendingRelease {
+		if p.Region != region {
+			continue
+		}
+		req := client.NewDescribeEIPRequest()
+		req.ProjectId = ucloud.String(p.ProjectID)
+		req.EIPIds = []string{p.EIPID}
+		resp, err := client.DescribeEIP(req)
+		if err != nil {
+			lg.Warnf("deferred release: region=%s eip=%s describe failed, retry next run: %v", region, p.EIPID, err)
+			continue
+		}
+		switch {
+		case len(resp.EIPSet) == 0:
+			lg.Infof("deferred release: region=%s eip=%s already gone", region, p.EIPID)
+		case strings.ToLower(resp.EIPSet[0].Status) != "free":
+			lg.Warnf("deferred release: region=%s eip=%s is %s again, not releasing it", region, p.EIPID, resp.EIPSet[0].Status)
+		default:
+			if err := releaseEIP(client, p.ProjectID, p.EIPID); err != nil {
+				lg.Warnf("deferred release: region=%s eip=%s ReleaseEIP failed, retry next run: %v", region, p.EIPID, err)
+				continue
+			}
+			lg.Infof("deferred release: region=%s released %s kept since %s", region, p.EIPID, p.Since.Format("2006-01-02 15:04:05"))
+		}
+		done = append(done, p.EIPID)
+	}
+	if len(done) == 0 {
+		return
+	}
+	state.update(key, func(ts *taskState) {
+		kept := ts.PendingRelease[:0]
+		for _, p := range ts.PendingRelease {
+			if p.Region == region && containsString(done, p.EIPID) {
+				continue
+			}
+			kept = append(kept, p)
+		}
+		ts.PendingRelease = kept
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
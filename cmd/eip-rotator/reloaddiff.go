@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// secretFields are shown as changed without their values in reload diffs
+var secretFields = map[string]bool{"public_key": true, "private_key": true, "discover_public_key": true, "discover_private_key": true}
+
+// restartFields are the fields reconcile restarts a running task for (its ticker and identity);
+// every other change applies in place from the task's next run
+var restartFields = map[string]bool{"interval_sec": true, "interval": true, "name": true}
+
+// configChanges lists the fields new changes relative to old as "field: old -> new", by their
+// config names, with secrets redacted
+func configChanges(old, new taskConfig) []string {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	var out []string
+	for i := 0; i < ov.NumField(); i++ {
+		a, b := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		f := ov.Type().Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = f.Name
+		}
+		if secretFields[name] {
+			out = append(out, name+": changed")
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s: %s -> %s", name, diffValue(a), diffValue(b)))
+	}
+	return out
+}
+
+func diffValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// credentialsChanged reports whether new uses other API keys than old
+func credentialsChanged(old, new taskConfig) bool {
+	return old.PublicKey != new.PublicKey || old.PrivateKey != new.PrivateKey ||
+		old.DiscoverPublicKey != new.DiscoverPublicKey || old.DiscoverPrivateKey != new.DiscoverPrivateKey
+}
+
+// needsRestart reports whether changes (from configChanges) touch a restart field
+func needsRestart(changes []string) bool {
+	for _, c := range changes {
+		name, _, _ := strings.Cut(c, ":")
+		if restartFields[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// logConfigChanges logs what a reload changes for a task that is already running: which fields
+// changed and how reconcile applies them
+func logConfigChanges(logger leveledLogger, key string, changes []string, t taskConfig) {
+	if len(changes) == 0 {
+		return
+	}
+	applied := "task restarts with the new config"
+	if !needsRestart(changes) {
+		applied = "applied in place from the next run"
+	}
+	logger.Infof("config diff: task %s key=%s modified (%s): %s", taskLabel(t), key, applied, strings.Join(changes, "; "))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigChanges(t *testing.T) {
+	base := taskConfig{Name: "web", PublicKey: "pub", PrivateKey: "priv", Projects: []string{"org-a"}, Region: "cn-bj2", Interval: 600}
+	tests := []struct {
+		name string
+		edit func(*taskConfig)
+		want []string
+	}{
+		{name: "unchanged", edit: func(*taskConfig) {}},
+		{name: "interval", edit: func(t *taskConfig) { t.Interval = 900 }, want: []string{"interval_sec: 600 -> 900"}},
+		{name: "list", edit: func(t *taskConfig) { t.Projects = []string{"org-a", "org-b"} }, want: []string{`project_ids: ["org-a"] -> ["org-a","org-b"]`}},
+		{name: "secrets are redacted", edit: func(t *taskConfig) { t.PrivateKey = "new-priv" }, want: []string{"private_key: changed"}},
+		{
+			name: "several fields in declaration order",
+			edit: func(t *taskConfig) { t.Region, t.PostHookCmd = "hk", []string{"notify"} },
+			want: []string{`region: "cn-bj2" -> "hk"`, `post_hook_cmd: null -> ["notify"]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base
+			next.Projects = append([]string(nil), base.Projects...)
+			tt.edit(&next)
+			if got := configChanges(base, next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configChanges = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRestart(t *testing.T) {
+	base := taskConfig{Name: "web", PublicKey: "pub", PrivateKey: "priv", Projects: []string{"org-a"}, Region: "cn-bj2", Interval: 600}
+	tests := []struct {
+		name    string
+		edit    func(*taskConfig)
+		restart bool
+	}{
+		{name: "interval_sec", edit: func(t *taskConfig) { t.Interval = 900 }, restart: true},
+		{name: "interval", edit: func(t *taskConfig) { t.IntervalDuration = "30m" }, restart: true},
+		{name: "name", edit: func(t *taskConfig) { t.Name = "web-2" }, restart: true},
+		{name: "projects apply in place", edit: func(t *taskConfig) { t.Projects = []string{"org-b"} }},
+		{name: "credentials apply in place", edit: func(t *taskConfig) { t.PrivateKey = "new-priv" }},
+		{name: "hooks apply in place", edit: func(t *taskConfig) { t.PostHookCmd = []string{"notify"} }},
+		{name: "mixed changes restart", edit: func(t *taskConfig) { t.Region, t.Interval = "hk", 900 }, restart: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base
+			tt.edit(&next)
+			changes := configChanges(base, next)
+			if len(changes) == 0 {
+				t.Fatal("the edit changed nothing")
+			}
+			if got := needsRestart(changes); got != tt.restart {
+				t.Errorf("needsRestart(%q) = %v, want %v", changes, got, tt.restart)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// hostSkip records a host that was discovered but deliberately not rotated
+type hostSkip struct {
+	Region    string
+	ProjectID string
+	UHostID   string
+	EIPID     string
+	Reason    string
+}
+
+// rotateReport summarizes one rotateOnce call across all of its regions
+type rotateReport struct {
+	Discovered int
+	Rotated    int
+	Failed     int
+	Skipped    []hostSkip
+}
+
+func (r *rotateReport) merge(o rotateReport) {
+	r.Discovered += o.Discovered
+	r.Rotated += o.Rotated
+	r.Failed += o.Failed
+	r.Skipped = append(r.Skipped, o.Skipped...)
+}
+
+func (r *rotateReport) skip(b hostBinding, reason string) {
+	r.Skipped = append(r.Skipped, hostSkip{Region: b.Region, ProjectID: b.ProjectID, UHostID: b.UHostID, EIPID: b.EIPID, Reason: reason})
+}
+
+func (r rotateReport) logSummary(lg leveledLogger) {
+	lg.Infof("run summary: discovered=%d rotated=%d skipped=%d failed=%d", r.Discovered, r.Rotated, len(r.Skipped), r.Failed)
+	for _, s := range r.Skipped {
+		lg.Infof("skipped region=%s project=%s host=%s eip=%s: %s", s.Region, s.ProjectID, s.UHostID, s.EIPID, s.Reason)
+	}
+}
+
+// runSummary is the single JSON line run mode prints to stdout for CI wrappers
+type runSummary struct {
+	Discovered  int     `json:"discovered"`
+	Rotated     int     `json:"rotated"`
+	Skipped     int     `json:"skipped"`
+	Failed      int     `json:"failed"`
+	DurationSec float64 `json:"duration_sec"`
+	OK          bool    `json:"ok"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func writeRunSummary(w io.Writer, r rotateReport, dur time.Duration, err error) error {
+	s := runSummary{
+		Discovered:  r.Discovered,
+		Rotated:     r.Rotated,
+		Skipped:     len(r.Skipped),
+		Failed:      r.Failed,
+		DurationSec: dur.Seconds(),
+		OK:          err == nil,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return json.NewEncoder(w).Encode(s)
+}
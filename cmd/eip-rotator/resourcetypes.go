@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/user/eip-rotator/eiprotator"
+)
+
+// supportedResourceTypes are the BindEIP resource types an EIP can be rotated on
+var supportedResourceTypes = eiprotator.SupportedResourceTypes
+
+func validateResourceTypes(t taskConfig) error {
+	for _, typ := range t.ResourceTypes {
+		if !containsString(supportedResourceTypes, strings.ToLower(strings.TrimSpace(typ))) {
+			return invalidTask(t, "unsupported resource type %q in resource_types (want one of %s)", typ, strings.Join(supportedResourceTypes, ","))
+		}
+	}
+	return nil
+}
+
+// resourceTypeOf derives the resource type from a resource id ("ulb-xxxx" -> "ulb"), for EIPs known
+// only by the id in their allocation marker; ids without a recognised prefix are taken as uhost
+func resourceTypeOf(id string) string {
+	if prefix, _, ok := strings.Cut(id, "-"); ok && containsString(supportedResourceTypes, prefix) {
+		return prefix
+	}
+	return "uhost"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRotateOnceResourceTypes(t *testing.T) {
+	setup := func(t *testing.T) (*fakeUCloud, map[string]string) {
+		f := newFakeUCloud(t)
+		f.add("cn-bj2", "org-test", boundEIP("eip-host01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+		f.add("cn-bj2", "org-test", boundEIP("eip-db01", "106.75.1.2", "udb", "udb-mysql01", "mysql-01"))
+		// the ResourceType each bind call carried, by "Action eip-id"
+		types := map[string]string{}
+		f.fail = func(action string, p url.Values) (int, string) {
+			if action == "BindEIP" || action == "UnBindEIP" {
+				types[action+" "+p.Get("EIPId")] = p.Get("ResourceType")
+			}
+			return 0, ""
+		}
+		return f, types
+	}
+
+	t.Run("all supported types by default", func(t *testing.T) {
+		f, types := setup(t)
+		report, err := rotateOnce(context.Background(), testTask("org-test"))
+		if err != nil || report.Rotated != 2 {
+			t.Fatalf("report = %+v, err = %v; want both rotated", report, err)
+		}
+		want := map[string]string{
+			"UnBindEIP eip-host01": "uhost", "BindEIP eip-new1": "uhost",
+			"UnBindEIP eip-db01": "udb", "BindEIP eip-new2": "udb",
+		}
+		if !reflect.DeepEqual(types, want) {
+			t.Errorf("resource types passed:\n got %v\nwant %v", types, want)
+		}
+		if got := f.boundTo("udb-mysql01"); !reflect.DeepEqual(got, []string{"eip-new2"}) {
+			t.Errorf("udb-mysql01 bound to %v, want [eip-new2]", got)
+		}
+	})
+
+	t.Run("narrowed by resource_types", func(t *testing.T) {
+		f, _ := setup(t)
+		task := testTask("org-test")
+		task.ResourceTypes = []string{"udb"}
+		report, err := rotateOnce(context.Background(), task)
+		if err != nil || report.Rotated != 1 {
+			t.Fatalf("report = %+v, err = %v; want only the udb rotated", report, err)
+		}
+		if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-host01"}) {
+			t.Errorf("uhost-web01 bound to %v, want it untouched", got)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// unboundEIP is a free EIP with remark for add
+func unboundEIP(eipID, ip, remark string) unet.UnetEIPSet {
+	e := boundEIP(eipID, ip, "", "", "")
+	e.Status, e.Resource, e.Remark = "free", unet.UnetEIPResourceSet{}, remark
+	return e
+}
+
+func TestRotateOnceResumesInterruptedSwap(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	// allocated for web-01 by a run that stopped before the swap
+	f.add("cn-bj2", "org-test", unboundEIP("eip-marked01", "117.50.1.1", allocationMarker(task, "uhost-web01")))
+	// allocated for web-03 by a run that stopped between unbinding the old EIP and binding this one
+	f.add("cn-bj2", "org-test", unboundEIP("eip-marked03", "117.50.1.3", allocationMarker(task, "uhost-web03")))
+
+	report, err := rotateOnce(context.Background(), task)
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Rotated != 2 || report.Failed != 0 {
+		t.Fatalf("report = %+v, want 2 rotated", report)
+	}
+	if got := f.callsOf("AllocateEIP"); len(got) != 0 {
+		t.Errorf("allocated %v, want the marked EIPs reused", got)
+	}
+	for host, eip := range map[string]string{"uhost-web01": "eip-marked01", "uhost-web03": "eip-marked03"} {
+		if got := f.boundTo(host); !reflect.DeepEqual(got, []string{eip}) {
+			t.Errorf("%s bound to %v, want [%s]", host, got, eip)
+		}
+		if e, _ := f.eip(eip); e.Remark != boundMarker(task) {
+			t.Errorf("%s remark = %q, want the allocation marker replaced by %q", eip, e.Remark, boundMarker(task))
+		}
+	}
+}
+
+func TestRotateOnceDoesNotResumeCompletedSwap(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	task.ReleaseOld = new(bool)
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+
+	// the first run binds eip-new1, the second replaces it and keeps it unbound; the third must not
+	// take it for an interrupted swap and put web-01 back on it
+	for run := 1; run <= 3; run++ {
+		if _, err := rotateOnce(context.Background(), task); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new3"}) {
+		t.Errorf("web-01 bound to %v, want [eip-new3]", got)
+	}
+	if got := f.callsOf("BindEIP eip-new1"); len(got) != 1 {
+		t.Errorf("eip-new1 bound %d times, want once", len(got))
+	}
+}
+
+func TestRotateOnceDoesNotResumePendingRelease(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	task.DeferredRelease = true
+	// an old EIP that still carries the marker of the swap that once bound it
+	old := boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01")
+	old.Remark = allocationMarker(task, "uhost-web01")
+	f.add("cn-bj2", "org-test", old)
+
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	// the deferred release fails, so eip-old01 stays free, marked and pending
+	f.fail = func(action string, p url.Values) (int, string) {
+		if action == "ReleaseEIP" && p.Get("EIPId") == "eip-old01" {
+			return 8044, "release refused"
+		}
+		return 0, ""
+	}
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got := f.callsOf("BindEIP eip-old01"); len(got) != 0 {
+		t.Errorf("pending eip-old01 was bound again: %v", got)
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new2"}) {
+		t.Errorf("web-01 bound to %v, want [eip-new2]", got)
+	}
+}
+
+func TestKeptEIPIsNeitherResumedNorCleanedUp(t *testing.T) {
+	f := newFakeUCloud(t)
+	task := testTask("org-test")
+	noRelease = true
+	t.Cleanup(func() { noRelease = false })
+	// an old EIP that still carries the marker of the swap that once bound it
+	old := boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01")
+	old.Remark = allocationMarker(task, "uhost-web01")
+	f.add("cn-bj2", "org-test", old)
+
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if e, _ := f.eip("eip-old01"); e.Remark != keptMarker(task) {
+		t.Errorf("kept eip-old01 remark = %q, want %q", e.Remark, keptMarker(task))
+	}
+	if _, err := rotateOnce(context.Background(), task); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got := f.callsOf("BindEIP eip-old01"); len(got) != 0 {
+		t.Errorf("kept eip-old01 was bound again: %v", got)
+	}
+
+	var out strings.Builder
+	if err := runCleanup(&out, []taskConfig{task}, true, 0); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	for _, kept := range []string{"eip-old01", "eip-new1"} {
+		if _, ok := f.eip(kept); !ok {
+			t.Errorf("cleanup released kept %s", kept)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRotateOnceEndToEnd(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+
+	report, err := rotateOnce(context.Background(), testTask("org-test"))
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Discovered != 2 || report.Rotated != 2 || report.Failed != 0 {
+		t.Fatalf("report = %+v, want 2 discovered, 2 rotated", report)
+	}
+
+	want := []string{
+		"AllocateEIP", "UnBindEIP eip-aaaa01", "BindEIP eip-new1", "UpdateEIPAttribute eip-new1", "ReleaseEIP eip-aaaa01",
+		"AllocateEIP", "UnBindEIP eip-aaaa02", "BindEIP eip-new2", "UpdateEIPAttribute eip-new2", "ReleaseEIP eip-aaaa02",
+	}
+	if got := f.mutations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("mutations:\n got %v\nwant %v", got, want)
+	}
+	for host, eip := range map[string]string{"uhost-web01": "eip-new1", "uhost-web02": "eip-new2"} {
+		if got := f.boundTo(host); !reflect.DeepEqual(got, []string{eip}) {
+			t.Errorf("%s bound to %v, want [%s]", host, got, eip)
+		}
+	}
+	for _, old := range []string{"eip-aaaa01", "eip-aaaa02"} {
+		if _, ok := f.eip(old); ok {
+			t.Errorf("old %s was not released", old)
+		}
+	}
+	// the unrelated free EIP is left alone
+	if e, ok := f.eip("eip-aaaa03"); !ok || e.Remark != "spare for the office VPN" {
+		t.Errorf("eip-aaaa03 = %+v, %v; want it untouched", e, ok)
+	}
+	// the new EIP has the old one's spec
+	if e, _ := f.eip("eip-new1"); e.Bandwidth != 5 || e.PayMode != "Bandwidth" || e.ChargeType != "Dynamic" || e.EIPAddr[0].OperatorName != "Bgp" {
+		t.Errorf("new EIP spec = %+v", e)
+	}
+}
+
+func TestAllocateFailureIsAHostFailure(t *testing.T) {
+	for _, failFast := range []bool{false, true} {
+		f := newFakeUCloud(t)
+		f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+		f.fail = func(action string, p url.Values) (int, string) {
+			if action == "AllocateEIP" {
+				return 8010, "allocation refused"
+			}
+			return 0, ""
+		}
+		task := testTask("org-test")
+		task.FailFast = failFast
+
+		report, err := rotateOnce(context.Background(), task)
+		want := 2
+		if failFast {
+			want = 1
+		}
+		if report.Failed != want || len(f.callsOf("AllocateEIP")) != want {
+			t.Errorf("fail_fast=%v: report = %+v after %d AllocateEIP calls, want %d failed", failFast, report, len(f.callsOf("AllocateEIP")), want)
+		}
+		if err == nil || strings.Count(err.Error(), "allocation refused") != want {
+			t.Errorf("fail_fast=%v: err = %v, want each host's AllocateEIP error once", failFast, err)
+		}
+	}
+}
+
+func TestPostHookFailureIsNotCountedAsRotated(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+	task := testTask("org-test")
+	task.PostHookCmd = []string{"false"}
+
+	report, err := rotateOnce(context.Background(), task)
+	if err == nil {
+		t.Fatal("rotateOnce succeeded, want the post hook failures")
+	}
+	if report.Rotated != 0 || report.Failed != 2 {
+		t.Errorf("report = %+v, want 0 rotated, 2 failed", report)
+	}
+}
+
+func TestReleaseFailureIsNotCountedAsRotated(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+	f.fail = func(action string, p url.Values) (int, string) {
+		if action == "ReleaseEIP" && p.Get("EIPId") == "eip-aaaa01" {
+			return 8044, "release refused"
+		}
+		return 0, ""
+	}
+	task := testTask("org-test")
+	task.FailOnReleaseError = true
+
+	report, err := rotateOnce(context.Background(), task)
+	if err == nil {
+		t.Fatal("rotateOnce succeeded, want the release failure")
+	}
+	if report.Rotated != 1 || report.Failed != 1 {
+		t.Errorf("report = %+v, want 1 rotated, 1 failed", report)
+	}
+}
+
+func TestFirewallLostDuringSwapFailsTheHost(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.firewalls["uhost-web01"] = "firewall-web"
+	f.fail = func(action string, p url.Values) (int, string) {
+		switch action {
+		case "BindEIP":
+			f.firewalls["uhost-web01"] = "firewall-default"
+		case "GrantFirewall":
+			return 8100, "grant refused"
+		}
+		return 0, ""
+	}
+	task := testTask("org-test")
+	task.PreserveFirewall = true
+
+	report, err := rotateOnce(context.Background(), task)
+	if err == nil || !strings.Contains(err.Error(), "firewall-web") {
+		t.Fatalf("rotateOnce error = %v, want the firewall failure", err)
+	}
+	if report.Rotated != 0 || report.Failed != 1 {
+		t.Errorf("report = %+v, want 0 rotated, 1 failed", report)
+	}
+	// binding the old EIP back would not bring the firewall back, so the swap stands
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new1"}) {
+		t.Errorf("uhost-web01 bound to %v, want [eip-new1]", got)
+	}
+	if got := f.callsOf("GrantFirewall"); len(got) != 1 {
+		t.Errorf("GrantFirewall calls = %v, want one", got)
+	}
+}
+
+func TestRotateOnceHostWithTwoEIPs(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-line1", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-line2", "106.75.1.2", "uhost", "uhost-web01", "web-01"))
+
+	report, err := rotateOnce(context.Background(), testTask("org-test"))
+	if err != nil {
+		t.Fatalf("rotateOnce: %v", err)
+	}
+	if report.Discovered != 2 || report.Rotated != 2 {
+		t.Fatalf("report = %+v, want both EIPs rotated", report)
+	}
+	// each swap touches only its own EIP; the other stays bound throughout
+	want := []string{
+		"AllocateEIP", "UnBindEIP eip-line1", "BindEIP eip-new1", "UpdateEIPAttribute eip-new1", "ReleaseEIP eip-line1",
+		"AllocateEIP", "UnBindEIP eip-line2", "BindEIP eip-new2", "UpdateEIPAttribute eip-new2", "ReleaseEIP eip-line2",
+	}
+	if got := f.mutations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("mutations:\n got %v\nwant %v", got, want)
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new1", "eip-new2"}) {
+		t.Errorf("web-01 bound to %v, want [eip-new1 eip-new2]", got)
+	}
+}
+
+func TestRotateOnceOneEIPOfTwoLeavesTheOtherBound(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-line1", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	f.add("cn-bj2", "org-test", boundEIP("eip-line2", "106.75.1.2", "uhost", "uhost-web01", "web-01"))
+	// the second swap fails at BindEIP and is rolled back
+	f.fail = func(action string, p url.Values) (int, string) {
+		if action == "BindEIP" && p.Get("EIPId") == "eip-new2" {
+			return 8042, "bind refused"
+		}
+		return 0, ""
+	}
+
+	report, _ := rotateOnce(context.Background(), testTask("org-test"))
+	if report.Rotated != 1 || report.Failed != 1 {
+		t.Fatalf("report = %+v, want 1 rotated, 1 failed", report)
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-line2", "eip-new1"}) {
+		t.Errorf("web-01 bound to %v, want [eip-line2 eip-new1]", got)
+	}
+}
+
+func TestRotateOnceSkipsEIPsBoundEarlierInTheRun(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+	first, second := testTask("org-test"), testTask("org-test")
+	second.Name = "test-again"
+	// two tasks of one invocation over the same project, as runFromConfig runs them
+	ctx := withRunEIPs(withRotationBudget(context.Background()))
+
+	if report, err := rotateOnce(ctx, first); err != nil || report.Rotated != 1 {
+		t.Fatalf("first task: report = %+v, err = %v; want 1 rotated", report, err)
+	}
+	report, _ := rotateOnce(ctx, second)
+	if report.Rotated != 0 {
+		t.Errorf("second task: report = %+v, want nothing rotated", report)
+	}
+	if got := f.callsOf("UnBindEIP"); !reflect.DeepEqual(got, []string{"UnBindEIP eip-old01"}) {
+		t.Errorf("unbinds = %v, want only eip-old01", got)
+	}
+	if got := f.boundTo("uhost-web01"); !reflect.DeepEqual(got, []string{"eip-new1"}) {
+		t.Errorf("web-01 bound to %v, want [eip-new1]", got)
+	}
+}
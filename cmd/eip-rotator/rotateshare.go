@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// force (--force) overrides max_rotate_percent
+var force bool
+
+// checkRotateShare refuses to rotate when batch would take more than MaxRotatePercent of the bound
+// EIPs of any project. It guards against a filter that, by mistake, selects the whole fleet.
+func checkRotateShare(task taskConfig, region string, batch []hostBinding, bound map[string]int) error {
+	if task.MaxRotatePercent <= 0 || task.MaxRotatePercent >= 100 {
+		return nil
+	}
+	selected := map[string]int{}
+	for _, b := range batch {
+		selected[b.ProjectID]++
+	}
+	projects := make([]string, 0, len(selected))
+	for p := range selected {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	for _, p := range projects {
+		total := bound[p]
+		if total == 0 {
+			continue
+		}
+		if pct := float64(selected[p]) * 100 / float64(total); pct > task.MaxRotatePercent {
+			return fmt.Errorf("refusing to rotate %d of %d bound EIPs (%.1f%%) in region=%s project=%s: above max_rotate_percent=%g, check the task's filters or rerun with --force", selected[p], total, pct, region, p, task.MaxRotatePercent)
+		}
+	}
+	return nil
+}
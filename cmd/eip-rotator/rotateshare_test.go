@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckRotateShare(t *testing.T) {
+	batch := []hostBinding{{ProjectID: "org-a"}, {ProjectID: "org-a"}, {ProjectID: "org-b"}}
+	tests := []struct {
+		name    string
+		percent float64
+		bound   map[string]int
+		wantErr string
+	}{
+		{name: "off", percent: 0, bound: map[string]int{"org-a": 2, "org-b": 1}},
+		{name: "100 is off", percent: 100, bound: map[string]int{"org-a": 2, "org-b": 1}},
+		{name: "within the share", percent: 50, bound: map[string]int{"org-a": 4, "org-b": 10}},
+		{name: "exactly the share", percent: 50, bound: map[string]int{"org-a": 4, "org-b": 2}},
+		{name: "above the share", percent: 50, bound: map[string]int{"org-a": 4, "org-b": 1}, wantErr: "1 of 1 bound EIPs (100.0%) in region=cn-bj2 project=org-b"},
+		{name: "first project over is reported", percent: 10, bound: map[string]int{"org-a": 4, "org-b": 1}, wantErr: "project=org-a"},
+		{name: "unknown totals are not checked", percent: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRotateShare(taskConfig{MaxRotatePercent: tt.percent}, "cn-bj2", batch, tt.bound)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkRotateShare: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxRotatePercentRefusesTheRun(t *testing.T) {
+	for _, forced := range []bool{false, true} {
+		f := newFakeUCloud(t)
+		f.load("cn-bj2", "org-test", "testdata/describe_eip.json")
+		task := testTask("org-test")
+		task.MaxRotatePercent = 50
+		prev := force
+		force = forced
+
+		report, err := rotateOnce(context.Background(), task)
+		force = prev
+		if forced {
+			if err != nil || report.Rotated != 2 {
+				t.Errorf("--force: report = %+v, %v; want both hosts rotated", report, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "max_rotate_percent=50") {
+			t.Errorf("err = %v, want the run refused", err)
+		}
+		if got := f.mutations(); len(got) != 0 {
+			t.Errorf("mutations = %v, want none", got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// runEIPs are the EIPs a run has put on hosts so far. Discovery later in the same run (an
+// overlapping region or project list, or another task of the invocation) sees them as bound
+// like any other and would rotate them again; they are dropped from its bindings instead.
+type runEIPs struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+type runEIPsKey struct{}
+
+// withRunEIPs starts an empty set for the run under ctx, unless ctx already carries one from an
+// enclosing run
+func withRunEIPs(ctx context.Context) context.Context {
+	if runEIPsFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, runEIPsKey{}, &runEIPs{ids: map[string]bool{}})
+}
+
+// runEIPsFrom returns the run's set; nil (outside a run) records nothing and excludes nothing
+func runEIPsFrom(ctx context.Context) *runEIPs {
+	s, _ := ctx.Value(runEIPsKey{}).(*runEIPs)
+	return s
+}
+
+// add records an EIP taken for a host in this run, whether allocated, resumed or claimed
+func (s *runEIPs) add(eipID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[eipID] = true
+}
+
+// exclude splits off the bindings of EIPs this run already put on a host; the caller still needs
+// them to know those hosts are bound
+func (s *runEIPs) exclude(bindings []hostBinding) (kept, excluded []hostBinding) {
+	if s == nil {
+		return bindings, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range bindings {
+		if s.ids[b.EIPID] {
+			stdLogger().Infof("skip eip=%s region=%s project=%s host=%s(%s): bound by this run, not rotated again", b.EIPID, b.Region, b.ProjectID, safeName(b.UHostName), b.UHostID)
+			excluded = append(excluded, b)
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return kept, excluded
+}
@@ -0,0 +1,26 @@
+package main
+
+import "context"
+
+type sdkMaxRetriesKey struct{}
+
+// withTaskSDKRetries makes clients created under the returned ctx use the task's sdk_max_retries
+func withTaskSDKRetries(ctx context.Context, t taskConfig) context.Context {
+	if t.SDKMaxRetries <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, sdkMaxRetriesKey{}, t.SDKMaxRetries)
+}
+
+// sdkMaxRetriesFor returns the task's sdk_max_retries, or def (the SDK default) when unset
+func sdkMaxRetriesFor(ctx context.Context, def int) int {
+	if n, ok := ctx.Value(sdkMaxRetriesKey{}).(int); ok {
+		return n
+	}
+	return def
+}
+
+// withTask scopes ctx to the task's own client settings (user agent, SDK retries)
+func withTask(ctx context.Context, t taskConfig) context.Context {
+	return withTaskSDKRetries(withTaskUserAgent(ctx, t), t)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pendingRelease is an old EIP kept after its swap, to be released on a later run
+type pendingRelease struct {
+	Region    string    `json:"region"`
+	ProjectID string    `json:"project_id"`
+	EIPID     string    `json:"eip_id"`
+	Since     time.Time `json:"since"`
+}
+
+// taskState is what a task remembers between runs, keyed by taskKey in the state file
+type taskState struct {
+	PendingRelease []pendingRelease `json:"pending_release,omitempty"`
+	// Cursor is the per-region offset of the next batch when RotateBatch/RotateFraction is set
+	Cursor map[string]int `json:"cursor,omitempty"`
+}
+
+// stateStore holds per-task state; with an empty path it lives in memory only
+type stateStore struct {
+	mu    sync.Mutex
+	path  string
+	Tasks map[string]*taskState `json:"tasks"`
+	// HostRotated is the last successful rotation per uhost, shared by all tasks so a host's
+	// cooldown holds across overlapping tasks and manual runs
+	HostRotated map[string]time.Time `json:"host_rotated,omitempty"`
+}
+
+var state = &stateStore{Tasks: map[string]*taskState{}}
+
+// openState loads --state-file; a missing file starts empty and is created on first write
+func openState(path string) error {
+	state.path = path
+	if path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return fmt.Errorf("parse state %s: %w", path, err)
+	}
+	if state.Tasks == nil {
+		state.Tasks = map[string]*taskState{}
+	}
+	return nil
+}
+
+// get returns a copy of the task's state
+func (s *stateStore) get(key string) taskState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts, ok := s.Tasks[key]; ok {
+		cp := *ts
+		cp.PendingRelease = append([]pendingRelease(nil), ts.PendingRelease...)
+		cp.Cursor = make(map[string]int, len(ts.Cursor))
+		for k, v := range ts.Cursor {
+			cp.Cursor[k] = v
+		}
+		return cp
+	}
+	return taskState{}
+}
+
+// pendingIDs returns the EIPs in every task's pending release list
+func (s *stateStore) pendingIDs() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := map[string]bool{}
+	for _, ts := range s.Tasks {
+		for _, p := range ts.PendingRelease {
+			ids[p.EIPID] = true
+		}
+	}
+	return ids
+}
+
+// lastRotated returns when host was last rotated by any task
+func (s *stateStore) lastRotated(host string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.HostRotated[host]
+	return t, ok
+}
+
+// markRotated records a successful rotation of host and persists the store
+func (s *stateStore) markRotated(host string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.HostRotated == nil {
+		s.HostRotated = map[string]time.Time{}
+	}
+	s.HostRotated[host] = at
+	if err := s.saveLocked(); err != nil {
+		stdLogger().Errorf("save state: %v", err)
+	}
+}
+
+// update applies fn to the task's state and persists the whole store
+func (s *stateStore) update(key string, fn func(*taskState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, ok := s.Tasks[key]
+	if !ok {
+		ts = &taskState{}
+		s.Tasks[key] = ts
+	}
+	fn(ts)
+	if err := s.saveLocked(); err != nil {
+		stdLogger().Errorf("save state: %v", err)
+	}
+}
+
+// saveLocked writes through a temp file so a crash never leaves a truncated state file
+func (s *stateStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".eip-rotator-state-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+// swap strategies; break-before-make leaves the host without a public IP between unbind and bind,
+// make-before-break binds the new EIP while the old one is still attached
+const (
+	strategyBreakBeforeMake = "break-before-make"
+	strategyMakeBeforeBreak = "make-before-break"
+)
+
+func validStrategy(s string) bool {
+	return s == "" || s == strategyBreakBeforeMake || s == strategyMakeBeforeBreak
+}
+
+func (t taskConfig) makeBeforeBreak() bool {
+	return t.Strategy == strategyMakeBeforeBreak
+}
+
+// sameEIP returns a non-empty reason when the replacement newEipID/newIPs is, or shares an address
+// with, b's old EIP
+func sameEIP(b hostBinding, newEipID string, newIPs []string) string {
+	if newEipID == b.EIPID {
+		return fmt.Sprintf("new EIP %s is the old one", newEipID)
+	}
+	for _, ip := range newIPs {
+		if containsString(b.EIPIPs, ip) {
+			return fmt.Sprintf("new EIP %s has the old address %s", newEipID, ip)
+		}
+	}
+	return ""
+}
+
+func unbindEIP(client *unet.UNetClient, b hostBinding, eipID string) error {
+	targetType, targetID := b.bindTarget()
+	req := client.NewUnBindEIPRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.EIPId = ucloud.String(eipID)
+	req.ResourceId = ucloud.String(targetID)
+	req.ResourceType = ucloud.String(targetType)
+	start := time.Now()
+	_, err := client.UnBindEIP(req)
+	observeAPICall("UnBindEIP", b.Region, start, err)
+	return err
+}
+
+func bindEIP(client *unet.UNetClient, b hostBinding, eipID string) error {
+	targetType, targetID := b.bindTarget()
+	req := client.NewBindEIPRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.EIPId = ucloud.String(eipID)
+	req.ResourceType = ucloud.String(targetType)
+	req.ResourceId = ucloud.String(targetID)
+	if b.PrivateIP != "" {
+		req.PrivateIP = ucloud.String(b.PrivateIP)
+	}
+	start := time.Now()
+	_, err := client.BindEIP(req)
+	observeAPICall("BindEIP", b.Region, start, err)
+	return err
+}
+
+// confirmBound checks that eipID now shows as bound to b's host
+func confirmBound(client *unet.UNetClient, b hostBinding, eipID string) error {
+	req := client.NewDescribeEIPRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.EIPIds = []string{eipID}
+	resp, err := client.DescribeEIP(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.EIPSet) == 0 {
+		return fmt.Errorf("%s not found after bind", eipID)
+	}
+	e := resp.EIPSet[0]
+	if strings.ToLower(e.Status) != "used" || e.Resource.ResourceID != b.UHostID {
+		return fmt.Errorf("%s is status=%s resource=%s after bind", eipID, e.Status, safeName(e.Resource.ResourceID))
+	}
+	return nil
+}
+
+// targetFirewall returns the UFirewall applied to b's bind target (the uhost, or the NIC for a
+// secondary-NIC EIP), "" when there is none. Firewalls belong to that resource, not to the EIP.
+func targetFirewall(client *unet.UNetClient, b hostBinding) (string, error) {
+	targetType, targetID := b.bindTarget()
+	req := client.NewDescribeFirewallRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.ResourceId = ucloud.String(targetID)
+	req.ResourceType = ucloud.String(targetType)
+	resp, err := client.DescribeFirewall(req)
+	if err != nil {
+		return "", fmt.Errorf("DescribeFirewall: %w", err)
+	}
+	if len(resp.DataSet) == 0 {
+		return "", nil
+	}
+	return resp.DataSet[0].FWId, nil
+}
+
+// ensureFirewall re-grants fwID to b's bind target if the swap left it with a different one
+func ensureFirewall(client *unet.UNetClient, b hostBinding, fwID string) error {
+	if fwID == "" {
+		return nil
+	}
+	current, err := targetFirewall(client, b)
+	if err != nil {
+		return err
+	}
+	if current == fwID {
+		return nil
+	}
+	targetType, targetID := b.bindTarget()
+	stdLogger().Warnf("region=%s host=%s(%s): firewall changed from %s to %s during the swap, granting %s again", b.Region, safeName(b.UHostName), b.UHostID, fwID, safeName(current), fwID)
+	req := client.NewGrantFirewallRequest()
+	req.ProjectId = ucloud.String(b.ProjectID)
+	req.FWId = ucloud.String(fwID)
+	req.ResourceId = ucloud.String(targetID)
+	req.ResourceType = ucloud.String(targetType)
+	if _, err := client.GrantFirewall(req); err != nil {
+		return fmt.Errorf("GrantFirewall %s: %w", fwID, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schedulerTotals accumulates every finished run over the scheduler's life for the summary logged
+// at shutdown; the same numbers are exported as counters
+type schedulerTotals struct {
+	mu         sync.Mutex
+	started    time.Time
+	runs       int
+	failedRuns int
+	rotated    int
+	failed     int
+	lastErr    map[string]string // task id -> error of its last run, "" when it succeeded
+}
+
+var totals = &schedulerTotals{started: time.Now(), lastErr: map[string]string{}}
+
+func (s *schedulerTotals) record(r runResult) {
+	id := taskID(r.Task)
+	failed := r.Err != nil && !errors.Is(r.Err, errNoBindings)
+	s.mu.Lock()
+	s.runs++
+	s.rotated += r.Report.Rotated
+	s.failed += r.Report.Failed
+	s.lastErr[id] = ""
+	if failed {
+		s.failedRuns++
+		s.lastErr[id] = r.Err.Error()
+	}
+	s.mu.Unlock()
+	result := "ok"
+	if failed {
+		result = "error"
+	}
+	metrics.AddCounter("eip_rotator_runs_total", "Scheduled runs finished, by result", 1, "task", id, "result", result)
+	metrics.AddCounter("eip_rotator_rotations_total", "EIPs rotated by scheduled runs", float64(r.Report.Rotated), "task", id)
+	metrics.AddCounter("eip_rotator_rotation_failures_total", "Hosts that failed to rotate in scheduled runs", float64(r.Report.Failed), "task", id)
+}
+
+// logSummary is the scheduler's last word: uptime, lifetime totals and each task's last error
+func (s *schedulerTotals) logSummary(logger leveledLogger, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logger.Infof("shutdown summary (%s): ran %s, %d runs (%d failed), %d EIPs rotated, %d hosts failed", reason, time.Since(s.started).Round(time.Second), s.runs, s.failedRuns, s.rotated, s.failed)
+	ids := make([]string, 0, len(s.lastErr))
+	for id := range s.lastErr {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if e := s.lastErr[id]; e != "" {
+			logger.Infof("shutdown summary: task %s last run failed: %s", id, strings.ReplaceAll(e, "\n", "; "))
+		} else {
+			logger.Infof("shutdown summary: task %s last run ok", id)
+		}
+	}
+}
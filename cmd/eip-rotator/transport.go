@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/user/eip-rotator/eiprotator"
+)
+
+// apiTransport is shared by every SDK client; nil keeps the SDK default, which already honors
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY from the environment
+var apiTransport http.RoundTripper
+
+// apiBaseURL overrides the SDK's API endpoint (--api-base-url), e.g. to point the clients at a
+// recorded-response fixture server or a private gateway; empty keeps https://api.ucloud.cn
+var apiBaseURL string
+
+// transportFor returns the transport SDK clients created under ctx should use: apiTransport bound
+// to ctx, so a canceled task or an expired --timeout aborts calls that are already in flight
+func transportFor(ctx context.Context) http.RoundTripper {
+	return eiprotator.ContextTransport(ctx, apiTransport)
+}
+
+// setupTransport applies --proxy, --ca-file and --insecure-skip-verify; an explicit proxy wins over
+// the environment variables, and a CA file is trusted in addition to the system roots
+func setupTransport(proxy, caFile string, insecure bool) error {
+	if proxy == "" && caFile == "" && !insecure {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid --proxy %q: want a URL like http://proxy.corp:3128", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+		stdLogger().Infof("routing UCloud API calls through proxy %s", u.Redacted())
+	}
+	if caFile != "" || insecure {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("read --ca-file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("--ca-file %s contains no PEM certificates", caFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	if insecure {
+		t.TLSClientConfig.InsecureSkipVerify = true
+		stdLogger().Warnf("TLS certificate verification is DISABLED for UCloud API calls (--insecure-skip-verify); credentials and responses can be intercepted")
+	}
+	apiTransport = t
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "context"
+
+// apiUserAgent is appended to the SDK's own User-Agent on every API call (--user-agent) so our
+// traffic can be picked out in UCloud's logs; empty means defaultUserAgent()
+var apiUserAgent string
+
+// defaultUserAgent names the tool and its build version
+func defaultUserAgent() string {
+	return "eip-rotator/" + version
+}
+
+type userAgentKey struct{}
+
+// withTaskUserAgent makes clients created under the returned ctx use the task's user_agent
+func withTaskUserAgent(ctx context.Context, t taskConfig) context.Context {
+	if t.UserAgent == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userAgentKey{}, t.UserAgent)
+}
+
+// userAgentFor picks the task's user agent, then --user-agent, then the build default
+func userAgentFor(ctx context.Context) string {
+	if ua, ok := ctx.Value(userAgentKey{}).(string); ok {
+		return ua
+	}
+	if apiUserAgent != "" {
+		return apiUserAgent
+	}
+	return defaultUserAgent()
+}
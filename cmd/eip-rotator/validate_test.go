@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrepareTask(t *testing.T) {
+	f := newFakeUCloud(t)
+	f.add("cn-bj2", "org-test", boundEIP("eip-old01", "106.75.1.1", "uhost", "uhost-web01", "web-01"))
+
+	tests := []struct {
+		name    string
+		edit    func(*taskConfig)
+		strict  bool
+		wantErr string
+		wantSec int
+	}{
+		{name: "defaults the interval", edit: func(*taskConfig) {}, wantSec: defaultIntervalSec},
+		{name: "clamps a short interval", edit: func(t *taskConfig) { t.Interval = 1 }, wantSec: minIntervalSec},
+		{name: "resolves interval", edit: func(t *taskConfig) { t.IntervalDuration = "2h" }, wantSec: 7200},
+		{name: "rejects a malformed interval", edit: func(t *taskConfig) { t.IntervalDuration = "1.5s" }, wantErr: "whole number of seconds"},
+		{name: "rejects a malformed region", edit: func(t *taskConfig) { t.Region = "CN_BJ2" }, wantErr: "does not look like a region id"},
+		{name: "rejects an unknown region when strict", edit: func(t *taskConfig) { t.Region = "cn-bj22" }, strict: true, wantErr: `unknown region "cn-bj22"`},
+		{name: "accepts a known region when strict", edit: func(*taskConfig) {}, strict: true, wantSec: defaultIntervalSec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := checkRegions
+			checkRegions = tt.strict
+			defer func() { checkRegions = prev }()
+			// as flagTask builds it: no name, interval and region straight from the flags
+			task := testTask("org-test")
+			task.Name = ""
+			tt.edit(&task)
+			err := prepareTask(&task)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prepareTask: %v", err)
+			}
+			if task.Interval != tt.wantSec {
+				t.Errorf("interval_sec = %d, want %d", task.Interval, tt.wantSec)
+			}
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "30m", want: 30 * time.Minute},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "45s", want: 45 * time.Second},
+		{in: "500ms", wantErr: true},
+		{in: "1.5s", wantErr: true},
+		{in: "-5m", wantErr: true},
+		{in: "0s", wantErr: true},
+		{in: "600", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseInterval(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseInterval(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIntervalWinsOverIntervalSec(t *testing.T) {
+	task := taskConfig{Interval: 600, IntervalDuration: "2h"}
+	if err := applyIntervalFloor(&task); err != nil || task.Interval != 7200 {
+		t.Errorf("interval_sec = %d, %v; want interval=2h to win with 7200", task.Interval, err)
+	}
+}
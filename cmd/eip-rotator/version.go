@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+// set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%FT%TZ)"
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("eip-rotator %s (commit %s, built %s)", version, commit, date)
+}
@@ -0,0 +1,49 @@
+package eiprotator
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/auth"
+	"github.com/ucloud/ucloud-sdk-go/ucloud/config"
+)
+
+// ContextTransport binds every request sent through base (http.DefaultTransport when nil) to ctx.
+// The SDK has no context support, so this is how a canceled caller aborts calls already in flight.
+func ContextTransport(ctx context.Context, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return ctxTransport{ctx: ctx, base: base}
+}
+
+type ctxTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t ctxTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(r.WithContext(t.ctx))
+}
+
+// client is a UNet client for region with cfg's credentials and client settings, bound to ctx
+func (cfg Config) client(ctx context.Context, region string) *unet.UNetClient {
+	credential := auth.NewCredential()
+	credential.PublicKey = cfg.PublicKey
+	credential.PrivateKey = cfg.PrivateKey
+	sdkCfg := config.NewConfig()
+	sdkCfg.Region = region
+	if cfg.BaseURL != "" {
+		sdkCfg.BaseUrl = cfg.BaseURL
+	}
+	if cfg.UserAgent != "" {
+		sdkCfg.UserAgent = cfg.UserAgent
+	}
+	if cfg.MaxRetries > 0 {
+		sdkCfg.MaxRetries = cfg.MaxRetries
+	}
+	client := unet.NewClient(&sdkCfg, &credential)
+	client.SetTransport(ContextTransport(ctx, cfg.Transport))
+	return client
+}
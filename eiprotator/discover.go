@@ -0,0 +1,166 @@
+// Package eiprotator is the read-only side of eip-rotator for other programs: it finds the EIPs a
+// rotation task would pick up, so reports, dashboards or approval tools can be built on the same
+// discovery without changing anything in the account.
+package eiprotator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+	"github.com/ucloud/ucloud-sdk-go/ucloud"
+)
+
+// PageSize is the Limit of each DescribeEIP page; without one the API returns only 20 EIPs
+const PageSize = 100
+
+// SupportedResourceTypes are the BindEIP resource types an EIP can be rotated on. uni (secondary
+// NICs) is not listed: those EIPs are found through the uhost that owns the NIC.
+var SupportedResourceTypes = []string{"uhost", "upm", "udhost", "ulb", "natgw", "vpngw", "vrouter", "udb", "udockhost", "hadoophost", "fortresshost", "ucdr", "dbaudit", "cube"}
+
+// Config selects the EIPs to discover, with the same meaning as the fields of an eip-rotator task
+type Config struct {
+	PublicKey  string
+	PrivateKey string
+	Regions    []string // required
+	Projects   []string // required, resolved project ids
+	// ResourceTypes narrows the resource types whose EIPs are returned; empty means every
+	// supported type
+	ResourceTypes []string
+	// Statuses are the EIP statuses returned; empty means "used", the status of a bound, healthy EIP
+	Statuses []string
+	// HostID, when set, returns only the EIPs bound to that resource
+	HostID string
+	// IncludeNamePatterns / ExcludeNamePatterns are path.Match globs on the resource name
+	IncludeNamePatterns []string
+	ExcludeNamePatterns []string
+	// MinAge leaves out EIPs created less than this long ago
+	MinAge time.Duration
+	// OnlyIPsInCIDR keeps only EIPs with an address inside one of these CIDRs (or single IPs)
+	OnlyIPsInCIDR []string
+
+	// BaseURL overrides the API endpoint
+	BaseURL string
+	// Transport carries the API calls (proxy, CA, ...); nil means http.DefaultTransport. Every
+	// call is bound to the ctx passed to DiscoverBindings.
+	Transport http.RoundTripper
+	// UserAgent is appended to the SDK's own; MaxRetries overrides the SDK's retry count when > 0
+	UserAgent  string
+	MaxRetries int
+}
+
+// Binding is one EIP bound to a resource, as DescribeEIP reports it
+type Binding struct {
+	Region    string
+	ProjectID string
+	EIPID     string
+	IPs       []string // every address of the EIP; dual-line EIPs have more than one
+	// Operator is the line of the first address (Bgp, International, ...)
+	Operator     string
+	ResourceType string
+	ResourceID   string
+	ResourceName string
+	// NICID is set when the EIP sits on a secondary network interface (uni-*) of a uhost;
+	// PrivateIP is the interface address it maps to
+	NICID      string
+	PrivateIP  string
+	Bandwidth  int
+	PayMode    string // Bandwidth, Traffic, ShareBandwidth, ...
+	ChargeType string // Dynamic, Month, Year
+	Tag        string
+	Remark     string
+	Status     string
+	Created    time.Time
+}
+
+// DiscoverBindings lists, region by region and project by project, the EIPs cfg selects. It only
+// calls DescribeEIP. A project that fails is reported in the returned error while the bindings of
+// the others are still returned.
+func DiscoverBindings(ctx context.Context, cfg Config) ([]Binding, error) {
+	if len(cfg.Regions) == 0 || len(cfg.Projects) == 0 {
+		return nil, errors.New("eiprotator: Config needs at least one region and one project")
+	}
+	filter, err := cfg.Filter()
+	if err != nil {
+		return nil, fmt.Errorf("eiprotator: %w", err)
+	}
+	var (
+		bindings []Binding
+		errs     []error
+	)
+	for _, region := range cfg.Regions {
+		client := cfg.client(ctx, region)
+		for _, project := range cfg.Projects {
+			if err := ctx.Err(); err != nil {
+				return bindings, errors.Join(append(errs, err)...)
+			}
+			resp, err := DescribeEIPs(client, project)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("DescribeEIP region=%s project=%s: %w", region, project, err))
+				continue
+			}
+			now := time.Now()
+			for _, e := range resp.EIPSet {
+				if reason, _ := filter.Skip(e, now); reason == Selected {
+					bindings = append(bindings, BindingOf(e, region, project))
+				}
+			}
+		}
+	}
+	return bindings, errors.Join(errs...)
+}
+
+// DescribeEIPs lists every EIP of project in the client's region, page by page. The response holds
+// the EIPs of all pages, with the counts of the last one.
+func DescribeEIPs(client *unet.UNetClient, project string) (*unet.DescribeEIPResponse, error) {
+	var all *unet.DescribeEIPResponse
+	for offset := 0; ; {
+		req := client.NewDescribeEIPRequest()
+		req.ProjectId = ucloud.String(project)
+		req.Limit = ucloud.Int(PageSize)
+		req.Offset = ucloud.Int(offset)
+		resp, err := client.DescribeEIP(req)
+		if err != nil {
+			return nil, err
+		}
+		if all == nil {
+			all = resp
+		} else {
+			all.EIPSet = append(all.EIPSet, resp.EIPSet...)
+			all.TotalCount, all.UnbindCount = resp.TotalCount, resp.UnbindCount
+		}
+		offset += len(resp.EIPSet)
+		if len(resp.EIPSet) == 0 || offset >= resp.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// BindingOf describes e, found in project and region
+func BindingOf(e unet.UnetEIPSet, region, project string) Binding {
+	b := Binding{
+		Region: region, ProjectID: project, EIPID: e.EIPId, IPs: addrIPs(e.EIPAddr),
+		ResourceType: e.Resource.ResourceType, ResourceID: e.Resource.ResourceID, ResourceName: e.Resource.ResourceName,
+		Bandwidth: e.Bandwidth, PayMode: e.PayMode, ChargeType: e.ChargeType,
+		Tag: e.Tag, Remark: e.Remark, Status: e.Status, Created: time.Unix(int64(e.CreateTime), 0),
+	}
+	if len(e.EIPAddr) > 0 {
+		b.Operator = e.EIPAddr[0].OperatorName
+	}
+	if strings.EqualFold(e.Resource.ResourceType, "uhost") && strings.EqualFold(e.Resource.SubResourceType, "uni") && e.Resource.SubResourceId != "" {
+		b.NICID, b.PrivateIP = e.Resource.SubResourceId, e.EIPBinding.PrivateIP
+	}
+	return b
+}
+
+func addrIPs(addrs []unet.UnetEIPAddrSet) []string {
+	ips := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return ips
+}
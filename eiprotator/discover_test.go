@@ -0,0 +1,98 @@
+package eiprotator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// describeServer answers DescribeEIP with eips, honouring Limit and Offset, and counts the calls
+func describeServer(t *testing.T, eips []unet.UnetEIPSet, calls *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "DescribeEIP" {
+			t.Errorf("unexpected request %v: %v", r.Form, err)
+		}
+		*calls++
+		offset, _ := strconv.Atoi(r.Form.Get("Offset"))
+		limit, _ := strconv.Atoi(r.Form.Get("Limit"))
+		end := offset + limit
+		if end > len(eips) {
+			end = len(eips)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Action": "DescribeEIPResponse", "RetCode": 0, "TotalCount": len(eips), "EIPSet": eips[offset:end]})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDiscoverBindings(t *testing.T) {
+	var eips []unet.UnetEIPSet
+	for i := 0; i < PageSize+5; i++ {
+		eips = append(eips, unet.UnetEIPSet{
+			EIPId: fmt.Sprintf("eip-%03d", i), Status: "used", Bandwidth: 5, PayMode: "Bandwidth", ChargeType: "Dynamic", Tag: "Default",
+			EIPAddr:  []unet.UnetEIPAddrSet{{IP: fmt.Sprintf("106.75.0.%d", i), OperatorName: "Bgp"}},
+			Resource: unet.UnetEIPResourceSet{ResourceType: "uhost", ResourceID: fmt.Sprintf("uhost-%03d", i), ResourceName: fmt.Sprintf("web-%03d", i)},
+		})
+	}
+	eips[1].Resource.ResourceType = "udb"
+	eips[2].Status = "frozen"
+	eips[3].Status, eips[3].Resource = "free", unet.UnetEIPResourceSet{}
+	calls := 0
+	srv := describeServer(t, eips, &calls)
+
+	got, err := DiscoverBindings(context.Background(), Config{Regions: []string{"cn-bj2"}, Projects: []string{"org-test"}, ResourceTypes: []string{"uhost"}, BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("DiscoverBindings: %v", err)
+	}
+	// the udb, frozen and free EIPs are left out; the last page is read too
+	if len(got) != PageSize+2 || calls != 2 {
+		t.Fatalf("got %d bindings in %d calls, want %d in 2", len(got), calls, PageSize+2)
+	}
+	want := Binding{
+		Region: "cn-bj2", ProjectID: "org-test", EIPID: "eip-104", IPs: []string{"106.75.0.104"}, Operator: "Bgp",
+		ResourceType: "uhost", ResourceID: "uhost-104", ResourceName: "web-104",
+		Bandwidth: 5, PayMode: "Bandwidth", ChargeType: "Dynamic", Tag: "Default", Status: "used", Created: got[len(got)-1].Created,
+	}
+	if last := got[len(got)-1]; !reflect.DeepEqual(last, want) {
+		t.Errorf("last binding:\n got %+v\nwant %+v", last, want)
+	}
+}
+
+func TestDiscoverBindingsCancelsCallsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	userAgents := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case userAgents <- r.UserAgent():
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() { close(release); srv.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := DiscoverBindings(ctx, Config{Regions: []string{"cn-bj2"}, Projects: []string{"org-test"}, BaseURL: srv.URL, UserAgent: "reports/1.0", MaxRetries: 1})
+	if err == nil {
+		t.Fatal("DiscoverBindings succeeded against a server that never answers")
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("returned %s after the cancel, want the call in flight aborted", took)
+	}
+	if ua := <-userAgents; !strings.Contains(ua, "reports/1.0") {
+		t.Errorf("User-Agent = %q, want it to carry Config.UserAgent", ua)
+	}
+}
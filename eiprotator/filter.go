@@ -0,0 +1,156 @@
+package eiprotator
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+// SkipReason says why a Filter left an EIP out; Selected means it was kept
+type SkipReason int
+
+const (
+	Selected         SkipReason = iota
+	SkipStatus                  // status not among the selected ones
+	SkipResourceType            // bound to a resource type not selected
+	SkipUnbound                 // not bound to any resource
+	SkipHost                    // bound to another resource than HostID
+	SkipName                    // resource name ruled out by the name patterns
+	SkipAge                     // created less than MinAge ago
+	SkipCIDR                    // no address inside OnlyIPsInCIDR
+)
+
+// Filter is the selection part of a Config, checked once so it can be applied EIP by EIP
+type Filter struct {
+	statuses []string
+	types    []string
+	hostID   string
+	include  []string
+	exclude  []string
+	minAge   time.Duration
+	cidrs    []netip.Prefix
+}
+
+// Filter validates the name patterns and CIDRs of cfg and returns its selection
+func (cfg Config) Filter() (*Filter, error) {
+	if err := ValidateNamePatterns(append(append([]string(nil), cfg.IncludeNamePatterns...), cfg.ExcludeNamePatterns...)); err != nil {
+		return nil, err
+	}
+	cidrs, err := ParseCIDRs(cfg.OnlyIPsInCIDR)
+	if err != nil {
+		return nil, err
+	}
+	f := &Filter{statuses: cfg.Statuses, types: cfg.ResourceTypes, hostID: cfg.HostID,
+		include: cfg.IncludeNamePatterns, exclude: cfg.ExcludeNamePatterns, minAge: cfg.MinAge, cidrs: cidrs}
+	if len(f.statuses) == 0 {
+		f.statuses = []string{"used"}
+	}
+	if len(f.types) == 0 {
+		f.types = SupportedResourceTypes
+	}
+	return f, nil
+}
+
+// Statuses are the EIP statuses the filter selects
+func (f *Filter) Statuses() []string { return f.statuses }
+
+// Skip reports whether e is selected and, when it is not, why; the detail reads well after "skip eip=...: "
+func (f *Filter) Skip(e unet.UnetEIPSet, now time.Time) (SkipReason, string) {
+	switch {
+	case !containsFold(f.statuses, e.Status):
+		return SkipStatus, fmt.Sprintf("status=%s", e.Status)
+	case !containsFold(f.types, e.Resource.ResourceType):
+		return SkipResourceType, fmt.Sprintf("resource type=%s", e.Resource.ResourceType)
+	case e.Resource.ResourceID == "":
+		return SkipUnbound, "empty resource id"
+	case f.hostID != "" && e.Resource.ResourceID != f.hostID:
+		return SkipHost, fmt.Sprintf("bound to %s", e.Resource.ResourceID)
+	}
+	if keep, why := MatchName(f.include, f.exclude, e.Resource.ResourceName); !keep {
+		return SkipName, why
+	}
+	if created := time.Unix(int64(e.CreateTime), 0); f.minAge > 0 && now.Sub(created) < f.minAge {
+		return SkipAge, fmt.Sprintf("too new, created %s ago", now.Sub(created).Round(time.Minute))
+	}
+	if len(f.cidrs) > 0 && !anyIPIn(addrIPs(e.EIPAddr), f.cidrs) {
+		return SkipCIDR, fmt.Sprintf("%s not in only_ips_in_cidr", strings.Join(addrIPs(e.EIPAddr), ","))
+	}
+	return Selected, ""
+}
+
+// ValidateNamePatterns rejects malformed globs up front, since path.Match only reports a bad
+// pattern when it is used
+func ValidateNamePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid host name pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// MatchName decides whether a resource named name is kept by include and exclude globs and says
+// why: an exclude match always drops it, and when include patterns are set it must match one of
+// them. No patterns keep every resource.
+func MatchName(include, exclude []string, name string) (bool, string) {
+	for _, p := range exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false, fmt.Sprintf("name %q matches exclude pattern %q", name, p)
+		}
+	}
+	if len(include) == 0 {
+		return true, ""
+	}
+	for _, p := range include {
+		if ok, _ := path.Match(p, name); ok {
+			return true, fmt.Sprintf("name %q matches include pattern %q", name, p)
+		}
+	}
+	return false, fmt.Sprintf("name %q matches no include pattern %v", name, include)
+}
+
+// ParseCIDRs parses an only_ips_in_cidr list; a bare address is taken as a single-IP prefix
+func ParseCIDRs(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, fmt.Errorf("only_ips_in_cidr: %q is neither a CIDR nor an IP", s)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// anyIPIn reports whether one of ips falls in one of prefixes
+func anyIPIn(ips []string, prefixes []netip.Prefix) bool {
+	for _, s := range ips {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			continue
+		}
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package eiprotator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ucloud/ucloud-sdk-go/services/unet"
+)
+
+func TestFilterSkip(t *testing.T) {
+	now := time.Now()
+	bound := func(name, ip, status string, age time.Duration) unet.UnetEIPSet {
+		return unet.UnetEIPSet{
+			EIPId: "eip-1", Status: status, CreateTime: int(now.Add(-age).Unix()),
+			EIPAddr:  []unet.UnetEIPAddrSet{{IP: ip, OperatorName: "Bgp"}},
+			Resource: unet.UnetEIPResourceSet{ResourceType: "uhost", ResourceID: "uhost-" + name, ResourceName: name},
+		}
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		eip  unet.UnetEIPSet
+		want SkipReason
+	}{
+		{"used uhost selected by default", Config{}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), Selected},
+		{"frozen left out by default", Config{}, bound("web-01", "106.75.1.1", "freeze", 48*time.Hour), SkipStatus},
+		{"statuses replace the default", Config{Statuses: []string{"Freeze"}}, bound("web-01", "106.75.1.1", "freeze", 48*time.Hour), Selected},
+		{"resource type narrowed", Config{ResourceTypes: []string{"udb"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), SkipResourceType},
+		{"unbound", Config{}, unet.UnetEIPSet{Status: "used", Resource: unet.UnetEIPResourceSet{ResourceType: "uhost"}}, SkipUnbound},
+		{"other host", Config{HostID: "uhost-web-02"}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), SkipHost},
+		{"include glob matches", Config{IncludeNamePatterns: []string{"web-*"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), Selected},
+		{"include glob misses", Config{IncludeNamePatterns: []string{"db-*"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), SkipName},
+		{"exclude wins over include", Config{IncludeNamePatterns: []string{"web-*"}, ExcludeNamePatterns: []string{"*-01"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), SkipName},
+		{"too young", Config{MinAge: 24 * time.Hour}, bound("web-01", "106.75.1.1", "used", time.Hour), SkipAge},
+		{"old enough", Config{MinAge: 24 * time.Hour}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), Selected},
+		{"inside the CIDR", Config{OnlyIPsInCIDR: []string{"106.75.0.0/16"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), Selected},
+		{"single IP", Config{OnlyIPsInCIDR: []string{"106.75.1.1"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), Selected},
+		{"outside the CIDR", Config{OnlyIPsInCIDR: []string{"117.50.0.0/16"}}, bound("web-01", "106.75.1.1", "used", 48*time.Hour), SkipCIDR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.cfg.Filter()
+			if err != nil {
+				t.Fatalf("Filter: %v", err)
+			}
+			if got, why := f.Skip(tt.eip, now); got != tt.want {
+				t.Errorf("Skip = %d (%s), want %d", got, why, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRejectsBadConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{IncludeNamePatterns: []string{"web-["}},
+		{ExcludeNamePatterns: []string{"[a-"}},
+		{OnlyIPsInCIDR: []string{"106.75.0.0/33"}},
+		{OnlyIPsInCIDR: []string{"not-an-ip"}},
+	} {
+		if _, err := cfg.Filter(); err == nil {
+			t.Errorf("Filter(%+v) accepted a malformed pattern or CIDR", cfg)
+		}
+	}
+}
@@ -2,7 +2,10 @@ module github.com/user/eip-rotator
 
 go 1.21
 
-require github.com/ucloud/ucloud-sdk-go v0.22.45
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/ucloud/ucloud-sdk-go v0.22.45
+)
 
 require (
 	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect